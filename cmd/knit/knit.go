@@ -1,9 +1,17 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/knitcodegen/knit/pkg/generator"
 	"github.com/knitcodegen/knit/pkg/knit"
@@ -18,61 +26,165 @@ var (
 	commit  = "none"
 )
 
+// exit codes reported when knit finishes with problems. Any other error,
+// such as an invalid flag, exits with 1.
+const (
+	// exitFailed is returned when any file fails to process
+	exitFailed = 2
+	// exitStale is returned when generated code is out of date
+	exitStale = 3
+)
+
 func main() {
-	(&cli.App{
+	err := (&cli.App{
 		Name:    "knit",
 		Usage:   "language & schema agnostic code generation toolkit",
 		Version: fmt.Sprintf("%s\n%s", version, commit),
 
-		UsageText: "DEFAULT: knit ./**/*.gen.go\n\t COMMAND: knit [global options] command [command options] [arguments...]",
+		UsageText: "DEFAULT: knit [global options] [files, directories or patterns...]\n\t EXAMPLE: knit './**/*.gen.go'\n\t COMMAND: knit [global options] command [command options] [arguments...]",
 		// Default Action
-		Flags: []cli.Flag{
-			&cli.BoolFlag{
-				Name:    "format",
-				Usage:   "Enable auto-formatting of .go source files",
-				Aliases: []string{"f"},
-				Value:   true,
-			},
+		Flags: append(processFlags(),
 			&cli.BoolFlag{
-				Name:  "verbose",
-				Usage: "Enable verbose logging",
+				Name:  "dry-run",
+				Usage: "Report files with stale generated code without writing any changes",
 				Value: false,
 			},
 			&cli.BoolFlag{
-				Name:  "parallel",
-				Usage: "Enable parallel file processing",
-				Value: true,
+				Name:  "diff",
+				Usage: "Print a unified diff of pending changes without writing them",
+				Value: false,
 			},
-		},
+		),
 		Action: func(c *cli.Context) error {
-			if c.NArg() == 0 {
-				return errors.New("at least one argument is required")
+			if c.Bool("dry-run") {
+				return check(c)
 			}
 
-			files := c.Args().Slice()
-
-			k := knit.New(&knit.Config{
-				Format:   c.Bool("format"),
-				Verbose:  c.Bool("verbose"),
-				Parallel: c.Bool("parallel"),
-			})
+			if c.Bool("diff") {
+				return diff(c)
+			}
 
-			k.ProcessFiles(files, func(res knit.ProcessResult) {
-				if res.Error != nil {
-					log.Printf("knit failed to process file: %s\n%+v", res.File, res.Error)
-				} else {
+			summary, err := processFiles(c, func(cfg *knit.Config) knit.OnFileProcessed {
+				return func(res knit.ProcessResult) {
 					log.Printf("knit processed file successfully: %s", res.File)
 				}
 			})
+			if err != nil {
+				return err
+			}
 
-			return nil
+			return failures(summary)
 		},
 		Commands: []*cli.Command{
+			{
+				Name:      "graph",
+				Usage:     "Prints the dependency graph from annotated files to inputs and templates",
+				UsageText: "knit graph [command options] [files, directories or patterns...]",
+				Flags: append(processFlags(),
+					&cli.StringFlag{
+						Name:  "output",
+						Usage: "Output format of the graph, either json or dot",
+						Value: "json",
+					},
+					&cli.PathFlag{
+						Name:  "dependents",
+						Usage: "Only print the annotated files that depend on the given input or template",
+					},
+				),
+				Action: func(c *cli.Context) error {
+					cfg, err := loadConfig(c)
+					if err != nil {
+						return err
+					}
+
+					files, err := resolveFiles(c, cfg)
+					if err != nil {
+						return err
+					}
+
+					graph, err := knit.New(cfg).Graph(files)
+					if err != nil {
+						return err
+					}
+
+					if c.IsSet("dependents") {
+						for _, file := range graph.Dependents(c.Path("dependents")) {
+							fmt.Fprintln(c.App.Writer, file)
+						}
+						return nil
+					}
+
+					var out []byte
+					switch c.String("output") {
+					case "json":
+						out, err = graph.JSON()
+						if err != nil {
+							return err
+						}
+					case "dot":
+						out = []byte(graph.DOT())
+					default:
+						return fmt.Errorf("undefined graph output format %s", c.String("output"))
+					}
+
+					_, err = c.App.Writer.Write(out)
+					return err
+				},
+			},
+			{
+				Name:      "watch",
+				Usage:     "Regenerates code whenever annotated files, inputs or templates change",
+				UsageText: "knit watch [command options] [files, directories or patterns...]",
+				Flags: append(processFlags(),
+					&cli.DurationFlag{
+						Name:  "debounce",
+						Usage: "Time to wait for further changes before regenerating code",
+						Value: knit.DefaultConfig().Debounce,
+					},
+				),
+				Action: func(c *cli.Context) error {
+					cfg, err := loadConfig(c)
+					if err != nil {
+						return err
+					}
+					if c.IsSet("debounce") {
+						cfg.Debounce = c.Duration("debounce")
+					}
+
+					files, err := resolveFiles(c, cfg)
+					if err != nil {
+						return err
+					}
+
+					ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+					defer stop()
+
+					log.Printf("knit watching %d files", len(files))
+					return knit.New(cfg).Watch(ctx, files, func(res knit.ProcessResult) {
+						if res.Error != nil {
+							log.Printf("knit failed to process file: %s\n%+v", res.File, res.Error)
+						} else if res.Modified {
+							log.Printf("knit processed file successfully: %s", res.File)
+						} else if cfg.Verbose {
+							log.Printf("knit generated code is up to date: %s", res.File)
+						}
+					})
+				},
+			},
+			{
+				Name:      "check",
+				Usage:     "Fails if any generated code blocks are out of date",
+				UsageText: "knit check [command options] [files...]",
+				Flags:     processFlags(),
+				Action: func(c *cli.Context) error {
+					return check(c)
+				},
+			},
 			{
 				Name:    "generate",
 				Usage:   "Runs the knit code generator using the specified options",
 				Aliases: []string{"gen"},
-				Flags: []cli.Flag{
+				Flags: append(configFlags(),
 					&cli.StringFlag{
 						Required: false,
 						Aliases:  []string{"l"},
@@ -94,7 +206,7 @@ func main() {
 						Value:    "",
 						Usage:    "template file",
 					},
-				},
+				),
 				Action: func(c *cli.Context) error {
 					opts := []*parser.Option{
 						{
@@ -111,7 +223,12 @@ func main() {
 						},
 					}
 
-					gen, err := generator.New(opts...)
+					cfg, err := loadConfig(c)
+					if err != nil {
+						return err
+					}
+
+					gen, err := generator.NewWithConfig(cfg.GeneratorConfig(), opts...)
 					if err != nil {
 						return err
 					}
@@ -131,4 +248,313 @@ func main() {
 			},
 		},
 	}).Run(os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// configFlags returns the flags shared by every command that loads the knit
+// configuration
+func configFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.PathFlag{
+			Name:    "config",
+			Usage:   "Path to the knit configuration file. Defaults to the nearest " + knit.ConfigFile,
+			Aliases: []string{"c"},
+		},
+		&cli.BoolFlag{
+			Name:  "verbose",
+			Usage: "Enable verbose logging",
+			Value: false,
+		},
+		&cli.BoolFlag{
+			Name:  "restrict-refs",
+			Usage: "Reject references from inputs to files outside of the project root",
+			Value: false,
+		},
+	}
+}
+
+// processFlags returns the flags shared by every command that processes
+// annotated files
+func processFlags() []cli.Flag {
+	return append(configFlags(),
+		&cli.StringSliceFlag{
+			Name:  "exclude",
+			Usage: "Skip files matching the pattern when walking directories and globs",
+		},
+		&cli.BoolFlag{
+			Name:    "format",
+			Usage:   "Enable auto-formatting of .go source files",
+			Aliases: []string{"f"},
+			Value:   true,
+		},
+		&cli.BoolFlag{
+			Name:  "parallel",
+			Usage: "Enable parallel file processing",
+			Value: true,
+		},
+		&cli.IntFlag{
+			Name:    "jobs",
+			Usage:   "Maximum number of files processed in parallel. Defaults to the number of CPUs",
+			Aliases: []string{"j"},
+		},
+		&cli.BoolFlag{
+			Name:  "ordered",
+			Usage: "Report processed files in the order they were provided",
+			Value: false,
+		},
+		&cli.BoolFlag{
+			Name:  "cache",
+			Usage: "Reuse generated code blocks whose options, input and template have not changed",
+			Value: false,
+		},
+		&cli.PathFlag{
+			Name:  "cache-dir",
+			Usage: "Directory generated code blocks are cached in",
+			Value: knit.DefaultCacheDir,
+		},
+		&cli.BoolFlag{
+			Name:  "keep-going",
+			Usage: "Keep processing files after a file fails to process",
+			Value: true,
+		},
+	)
+}
+
+// check processes the files passed as arguments in dry run mode and reports
+// every file whose generated code is out of date. A non-zero exit code is
+// returned if any file is stale or fails to process.
+func check(c *cli.Context) error {
+	var stale []string
+
+	summary, err := processFiles(c, func(cfg *knit.Config) knit.OnFileProcessed {
+		cfg.DryRun = true
+		return func(res knit.ProcessResult) {
+			if res.Modified {
+				stale = append(stale, res.File)
+			} else if cfg.Verbose {
+				log.Printf("knit generated code is up to date: %s", res.File)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(stale)
+	for _, file := range stale {
+		log.Printf("knit generated code is out of date: %s", file)
+	}
+
+	if err := failures(summary); err != nil {
+		return err
+	}
+
+	if len(stale) != 0 {
+		return cli.Exit(fmt.Sprintf("knit check failed: %d of %d files are out of date", len(stale), summary.Processed()), exitStale)
+	}
+
+	return nil
+}
+
+// diff processes the files passed as arguments without writing them and
+// prints a unified diff for every file that would be modified
+func diff(c *cli.Context) error {
+	diffs := map[string]string{}
+
+	summary, err := processFiles(c, func(cfg *knit.Config) knit.OnFileProcessed {
+		cfg.Diff = true
+		return func(res knit.ProcessResult) {
+			if res.Modified {
+				diffs[res.File] = res.Diff
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	modified := make([]string, 0, len(diffs))
+	for file := range diffs {
+		modified = append(modified, file)
+	}
+	sort.Strings(modified)
+
+	for _, file := range modified {
+		_, err := c.App.Writer.Write([]byte(diffs[file]))
+		if err != nil {
+			return err
+		}
+	}
+
+	return failures(summary)
+}
+
+// processFiles loads the configuration, lets setup adjust it and processes
+// the files passed as arguments until they are done or the process is
+// interrupted. Failed files are logged, the callback returned by setup is
+// called for every other file, one at a time. The summary of the run is
+// logged and returned.
+func processFiles(c *cli.Context, setup func(cfg *knit.Config) knit.OnFileProcessed) (*knit.Summary, error) {
+	cfg, err := loadConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	fn := setup(cfg)
+
+	files, err := resolveFiles(c, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	k := knit.New(cfg)
+
+	var (
+		mu      sync.Mutex
+		summary knit.Summary
+	)
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt)
+	defer stop()
+
+	startTime := time.Now()
+	k.ProcessFilesContext(ctx, files, func(res knit.ProcessResult) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		summary.Add(res)
+		if res.Error != nil {
+			log.Printf("knit failed to process file: %s\n%+v", res.File, res.Error)
+		} else {
+			fn(res)
+		}
+	})
+	summary.Time = time.Since(startTime)
+
+	log.Print(summary.String())
+	return &summary, nil
+}
+
+// loadConfig loads the knit configuration file, if any, and applies all flags
+// explicitly set on the command line on top of it
+func loadConfig(c *cli.Context) (*knit.Config, error) {
+	path := c.Path("config")
+	if len(path) == 0 {
+		found, err := knit.FindConfig(".")
+		if err != nil && err != knit.ErrConfigNotFound {
+			return nil, err
+		}
+		path = found
+	}
+
+	cfg := knit.DefaultConfig()
+	if len(path) != 0 {
+		loaded, err := knit.LoadConfig(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load config %s", path)
+		}
+		cfg = loaded
+	}
+
+	if c.IsSet("format") {
+		cfg.Format = c.Bool("format")
+	}
+	if c.IsSet("verbose") {
+		cfg.Verbose = c.Bool("verbose")
+	}
+	if c.IsSet("parallel") {
+		cfg.Parallel = c.Bool("parallel")
+	}
+	if c.IsSet("jobs") {
+		cfg.Jobs = c.Int("jobs")
+	}
+	if c.IsSet("ordered") {
+		cfg.Ordered = c.Bool("ordered")
+	}
+	if c.IsSet("cache") {
+		cfg.Cache = c.Bool("cache")
+	}
+	if c.IsSet("cache-dir") {
+		// the flag is relative to the working directory, not the project root
+		dir, err := filepath.Abs(c.Path("cache-dir"))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to resolve absolute path to cache directory")
+		}
+		cfg.CacheDir = dir
+	}
+	if c.IsSet("keep-going") {
+		cfg.FailFast = !c.Bool("keep-going")
+	}
+	if c.IsSet("restrict-refs") {
+		cfg.RestrictRefs = c.Bool("restrict-refs")
+	}
+	cfg.Exclude = append(cfg.Exclude, c.StringSlice("exclude")...)
+	if cfg.Cache {
+		cfg.Version = buildVersion()
+	}
+
+	return cfg, nil
+}
+
+// buildVersion identifies the running knit build for the cache. Release
+// builds are identified by their version, all other builds such as those of
+// go install and go run by the hash of their executable, so changes to knit
+// itself invalidate cached code blocks.
+func buildVersion() string {
+	v := fmt.Sprintf("%s %s", version, commit)
+	if version != "dev" {
+		return v
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return v
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return v
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return v
+	}
+	return v + " " + hex.EncodeToString(h.Sum(nil))
+}
+
+// resolveFiles returns the files named by the command line arguments or,
+// if there are none, the files matched by the configured file patterns
+func resolveFiles(c *cli.Context, cfg *knit.Config) ([]string, error) {
+	files, err := knit.ResolveFiles(cfg, c.Args().Slice())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("at least one file is required")
+	}
+
+	return files, nil
+}
+
+// failures returns an error exiting with exitFailed if any file in the
+// summary failed to process
+func failures(summary *knit.Summary) error {
+	if len(summary.Failed) == 0 {
+		return nil
+	}
+
+	files := make([]string, 0, len(summary.Failed))
+	for _, res := range summary.Failed {
+		files = append(files, res.File)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		log.Printf("knit failed: %s", file)
+	}
+
+	return cli.Exit(fmt.Sprintf("knit failed to process %d of %d files", len(files), summary.Processed()), exitFailed)
 }
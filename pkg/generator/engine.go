@@ -0,0 +1,254 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/cbroglie/mustache"
+	"github.com/flosch/pongo2/v5"
+	"github.com/pkg/errors"
+)
+
+// TemplateEngine renders templates written in a template language
+type TemplateEngine interface {
+	// Render parses the template along with the include files and executes
+	// it with the given data
+	Render(tmpl string, includes []TemplateFile, data interface{}) (string, error)
+}
+
+// TemplateFile is a template parsed along with the template of a generator
+type TemplateFile struct {
+	// Name is the base name of the template file
+	Name string
+	// Text is the content of the template file
+	Text string
+}
+
+// engines are the built-in template engines
+var engines = map[string]TemplateEngine{
+	"text":          &textEngine{},
+	"text/template": &textEngine{},
+	"html":          &htmlEngine{},
+	"html/template": &htmlEngine{},
+	"django":        &djangoEngine{},
+	"pongo2":        &djangoEngine{},
+	"mustache":      &mustacheEngine{},
+}
+
+// textEngine renders text/template templates with the functions of FuncMap
+type textEngine struct{}
+
+func (e *textEngine) Render(tmpl string, includes []TemplateFile, data interface{}) (string, error) {
+	t := template.
+		New("knit").
+		Funcs(FuncMap())
+
+	// include files are named after their base name like template.ParseFiles
+	// does, the define blocks of all files are shared
+	for _, include := range includes {
+		_, err := t.New(include.Name).Parse(include.Text)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to parse include file %s", include.Name)
+		}
+	}
+
+	_, err := t.Parse(tmpl)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse template")
+	}
+
+	buf := &bytes.Buffer{}
+	err = t.Execute(buf, data)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to execute template")
+	}
+
+	return buf.String(), nil
+}
+
+// htmlEngine renders html/template templates with the functions of FuncMap,
+// escaping data according to its context in the HTML document
+type htmlEngine struct{}
+
+func (e *htmlEngine) Render(tmpl string, includes []TemplateFile, data interface{}) (string, error) {
+	t := htmltemplate.
+		New("knit").
+		Funcs(htmltemplate.FuncMap(FuncMap()))
+
+	for _, include := range includes {
+		_, err := t.New(include.Name).Parse(include.Text)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to parse include file %s", include.Name)
+		}
+	}
+
+	_, err := t.Parse(tmpl)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse template")
+	}
+
+	buf := &bytes.Buffer{}
+	err = t.Execute(buf, data)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to execute template")
+	}
+
+	return buf.String(), nil
+}
+
+// djangoEngine renders Django templates using pongo2. Include files can be
+// included, extended and imported by their base name.
+type djangoEngine struct{}
+
+func (e *djangoEngine) Render(tmpl string, includes []TemplateFile, data interface{}) (string, error) {
+	files := make(djangoLoader, len(includes))
+	for _, include := range includes {
+		files[include.Name] = unescaped(include.Text)
+	}
+
+	t, err := pongo2.NewSet("knit", files).FromString(unescaped(tmpl))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse template")
+	}
+
+	out, err := t.Execute(djangoContext(data))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to execute template")
+	}
+
+	return out, nil
+}
+
+// djangoExtends matches the extends tag of Django templates
+var djangoExtends = regexp.MustCompile(`\{%-?\s*extends\s`)
+
+// unescaped turns off escaping for a Django template, as knit generates code
+// rather than HTML. Templates extending another one are left as they are,
+// since only their blocks are rendered, within the template they extend.
+func unescaped(text string) string {
+	if djangoExtends.MatchString(text) {
+		return text
+	}
+	return "{% autoescape off %}" + text + "{% endautoescape %}"
+}
+
+// djangoContext returns the context of a Django template. The data is
+// available as data, and the keys of maps are also available as variables.
+func djangoContext(data interface{}) pongo2.Context {
+	ctx := pongo2.Context{}
+
+	value := reflect.ValueOf(data)
+	if value.Kind() == reflect.Map {
+		iter := value.MapRange()
+		for iter.Next() {
+			if key, ok := iter.Key().Interface().(string); ok {
+				ctx[key] = iter.Value().Interface()
+			}
+		}
+	}
+
+	ctx["data"] = data
+	return ctx
+}
+
+// djangoLoader loads the include files of Django templates by their name
+type djangoLoader map[string]string
+
+func (l djangoLoader) Abs(base, name string) string {
+	return name
+}
+
+func (l djangoLoader) Get(path string) (io.Reader, error) {
+	text, ok := l[path]
+	if !ok {
+		return nil, fmt.Errorf("template %s not found", path)
+	}
+	return strings.NewReader(text), nil
+}
+
+// mustacheEngine renders Mustache templates without escaping HTML. Include
+// files are partials named after their base name, with or without their
+// extension.
+type mustacheEngine struct{}
+
+func (e *mustacheEngine) Render(tmpl string, includes []TemplateFile, data interface{}) (string, error) {
+	partials := make(mustachePartials, 2*len(includes))
+	for _, include := range includes {
+		partials[include.Name] = include.Text
+		partials[strings.TrimSuffix(include.Name, filepath.Ext(include.Name))] = include.Text
+	}
+
+	t, err := mustache.ParseStringPartialsRaw(tmpl, partials, true)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse template")
+	}
+
+	out, err := t.Render(data)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to execute template")
+	}
+
+	return out, nil
+}
+
+// mustachePartials provides the partials of Mustache templates. Partials
+// are always parsed escaping HTML, so their variables are made raw.
+type mustachePartials map[string]string
+
+func (p mustachePartials) Get(name string) (string, error) {
+	text, ok := p[name]
+	if !ok {
+		return "", fmt.Errorf("partial %s not found", name)
+	}
+	return rawMustache(text), nil
+}
+
+// rawMustache rewrites the escaped variable tags of a Mustache template as
+// unescaped ones, reading tags like the parser does to follow changes of
+// the delimiters. Malformed tags are left for the parser to report.
+func rawMustache(text string) string {
+	otag, ctag := "{{", "}}"
+	b := &strings.Builder{}
+	for {
+		start := strings.Index(text, otag)
+		if start < 0 {
+			break
+		}
+		b.WriteString(text[:start+len(otag)])
+		text = text[start+len(otag):]
+
+		// unescaped variables in triple mustaches end with an extra brace
+		end := ctag
+		if strings.HasPrefix(text, "{") {
+			end = "}" + ctag
+		}
+		n := strings.Index(text, end)
+		if n < 0 {
+			break
+		}
+
+		tag := strings.TrimSpace(text[:n])
+		switch {
+		case len(tag) == 0 || strings.HasPrefix(text, "{"):
+		case tag[0] == '=' && strings.HasSuffix(tag, "=") && len(tag) > 1:
+			delims := strings.Fields(tag[1 : len(tag)-1])
+			if len(delims) == 2 {
+				otag, ctag = delims[0], delims[1]
+			}
+		case !strings.ContainsRune("#^/!>&=", rune(tag[0])):
+			b.WriteString("&")
+		}
+
+		b.WriteString(text[:n+len(end)])
+		text = text[n+len(end):]
+	}
+	b.WriteString(text)
+	return b.String()
+}
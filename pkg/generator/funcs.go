@@ -0,0 +1,416 @@
+package generator
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/Masterminds/sprig"
+)
+
+// FuncMap returns the functions available to text and html templates, the
+// sprig functions extended by functions for generating code
+func FuncMap() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+
+	for name, fn := range map[string]interface{}{
+		"words":              words,
+		"pascalCase":         pascalCase,
+		"camelCase":          camelCase,
+		"snakeCase":          snakeCase,
+		"screamingSnakeCase": screamingSnakeCase,
+		"kebabCase":          kebabCase,
+		"goName":             goName,
+		"goPrivateName":      goPrivateName,
+		"tsName":             tsName,
+		"tsTypeName":         tsTypeName,
+		"pyName":             pyName,
+		"pyClassName":        pyClassName,
+		"rustName":           rustName,
+		"rustTypeName":       rustTypeName,
+		"isReserved":         isReserved,
+		"escapeReserved":     escapeReserved,
+		"pluralize":          pluralize,
+		"singularize":        singularize,
+		"sortedKeys":         sortedKeys,
+		"indentLines":        indentLines,
+		"prefixLines":        prefixLines,
+		"dedent":             dedent,
+		"goType":             goType,
+		"tsType":             tsType,
+		"operations":         operations,
+		"parametersIn":       parametersIn,
+		"requestBody":        requestBody,
+		"responsesByStatus":  responsesByStatus,
+		"refName":            refNameOf,
+		"schemaName":         schemaName,
+	} {
+		funcs[name] = fn
+	}
+
+	return funcs
+}
+
+// commonInitialisms are the initialisms kept in upper case in Go names
+var commonInitialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true,
+	"CSV": true, "DNS": true, "EOF": true, "GUID": true, "HTML": true,
+	"HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true,
+	"JWT": true, "LHS": true, "OS": true, "QPS": true, "RAM": true,
+	"RHS": true, "RPC": true, "SLA": true, "SMTP": true, "SQL": true,
+	"SSH": true, "TCP": true, "TLS": true, "TTL": true, "UDP": true,
+	"UI": true, "UID": true, "URI": true, "URL": true, "UTF8": true,
+	"UUID": true, "VM": true, "XML": true, "XMPP": true, "XSRF": true,
+	"XSS": true,
+}
+
+// reservedWords are the keywords of the languages names are escaped for
+var reservedWords = map[string]map[string]bool{
+	"go": wordSet(`break case chan const continue default defer else
+		fallthrough for func go goto if import interface map package range
+		return select struct switch type var`),
+	"ts": wordSet(`await break case catch class const continue debugger
+		default delete do else enum export extends false finally for
+		function if implements import in instanceof interface let new null
+		package private protected public return static super switch this
+		throw true try typeof var void while with yield`),
+	"python": wordSet(`False None True and as assert async await break
+		class continue def del elif else except finally for from global if
+		import in is lambda nonlocal not or pass raise return try while
+		with yield`),
+	"rust": wordSet(`Self abstract as async await become box break const
+		continue crate do dyn else enum extern false final fn for if impl
+		in let loop macro match mod move mut override priv pub ref return
+		self static struct super trait true try type typeof unsafe unsized
+		use virtual where while yield`),
+}
+
+// languageAliases maps alternative language names to those of
+// reservedWords
+var languageAliases = map[string]string{
+	"golang":     "go",
+	"typescript": "ts",
+	"javascript": "ts",
+	"js":         "ts",
+	"py":         "python",
+	"rs":         "rust",
+}
+
+// rustUnescapable are the Rust keywords that cannot be raw identifiers
+var rustUnescapable = wordSet("Self crate self super")
+
+func wordSet(list string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.Fields(list) {
+		set[word] = true
+	}
+	return set
+}
+
+// words splits a name into its words at non-alphanumeric characters and
+// changes of case, e.g. HTTPServer_url into HTTP, Server and url. A plural
+// s ending a run of upper case letters stays in the word, e.g. userIDs is
+// split into user and IDs.
+func words(s string) []string {
+	runes := []rune(s)
+	out := make([]string, 0)
+
+	start := -1
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				out = append(out, string(runes[start:i]))
+				start = -1
+			}
+			continue
+		}
+
+		if start >= 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if nextLower && runes[i+1] == 's' && (i+2 == len(runes) || !unicode.IsLower(runes[i+2])) {
+				nextLower = false
+			}
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				out = append(out, string(runes[start:i]))
+				start = i
+			}
+		}
+
+		if start < 0 {
+			start = i
+		}
+	}
+
+	if start >= 0 {
+		out = append(out, string(runes[start:]))
+	}
+	return out
+}
+
+// capitalize upper cases the first letter of a word and lower cases the
+// rest
+func capitalize(word string) string {
+	runes := []rune(strings.ToLower(word))
+	if len(runes) == 0 {
+		return ""
+	}
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// initialism returns the Go spelling of a word that is a common initialism,
+// or its plural, e.g. ID and IDs
+func initialism(word string) (string, bool) {
+	upper := strings.ToUpper(word)
+	if commonInitialisms[upper] {
+		return upper, true
+	}
+	if len(upper) > 2 && strings.HasSuffix(upper, "S") && commonInitialisms[upper[:len(upper)-1]] {
+		return upper[:len(upper)-1] + "s", true
+	}
+	return "", false
+}
+
+func pascalCase(s string) string {
+	sb := &strings.Builder{}
+	for _, word := range words(s) {
+		sb.WriteString(capitalize(word))
+	}
+	return sb.String()
+}
+
+func camelCase(s string) string {
+	sb := &strings.Builder{}
+	for i, word := range words(s) {
+		if i == 0 {
+			sb.WriteString(strings.ToLower(word))
+		} else {
+			sb.WriteString(capitalize(word))
+		}
+	}
+	return sb.String()
+}
+
+func snakeCase(s string) string {
+	return strings.ToLower(strings.Join(words(s), "_"))
+}
+
+func screamingSnakeCase(s string) string {
+	return strings.ToUpper(strings.Join(words(s), "_"))
+}
+
+func kebabCase(s string) string {
+	return strings.ToLower(strings.Join(words(s), "-"))
+}
+
+// safeIdentifier prefixes names starting with a digit with an underscore
+func safeIdentifier(name string) string {
+	if len(name) != 0 && unicode.IsDigit([]rune(name)[0]) {
+		return "_" + name
+	}
+	return name
+}
+
+// goName returns the exported Go name of s, keeping common initialisms in
+// upper case, e.g. UserID for user_id. Names starting with a digit are
+// prefixed with X so they stay exported.
+func goName(s string) string {
+	sb := &strings.Builder{}
+	for _, word := range words(s) {
+		if upper, ok := initialism(word); ok {
+			sb.WriteString(upper)
+		} else {
+			sb.WriteString(capitalize(word))
+		}
+	}
+	name := sb.String()
+	if len(name) != 0 && unicode.IsDigit([]rune(name)[0]) {
+		return "X" + name
+	}
+	return name
+}
+
+// goPrivateName returns the unexported Go name of s, keeping common
+// initialisms in upper case, e.g. userID for user_id
+func goPrivateName(s string) string {
+	sb := &strings.Builder{}
+	for i, word := range words(s) {
+		if i == 0 {
+			sb.WriteString(strings.ToLower(word))
+		} else if upper, ok := initialism(word); ok {
+			sb.WriteString(upper)
+		} else {
+			sb.WriteString(capitalize(word))
+		}
+	}
+	return escapeReserved("go", safeIdentifier(sb.String()))
+}
+
+func tsName(s string) string {
+	return escapeReserved("ts", safeIdentifier(camelCase(s)))
+}
+
+func tsTypeName(s string) string {
+	return escapeReserved("ts", safeIdentifier(pascalCase(s)))
+}
+
+func pyName(s string) string {
+	return escapeReserved("python", safeIdentifier(snakeCase(s)))
+}
+
+func pyClassName(s string) string {
+	return escapeReserved("python", safeIdentifier(pascalCase(s)))
+}
+
+func rustName(s string) string {
+	return escapeReserved("rust", safeIdentifier(snakeCase(s)))
+}
+
+func rustTypeName(s string) string {
+	return escapeReserved("rust", safeIdentifier(pascalCase(s)))
+}
+
+// language returns the canonical name of a language
+func language(lang string) string {
+	lang = strings.ToLower(lang)
+	if alias, ok := languageAliases[lang]; ok {
+		return alias
+	}
+	return lang
+}
+
+// isReserved reports whether name is a keyword of the language, one of go,
+// ts, python or rust
+func isReserved(lang string, name string) bool {
+	return reservedWords[language(lang)][name]
+}
+
+// escapeReserved escapes names that are keywords of the language by
+// appending an underscore, or as raw identifiers in Rust
+func escapeReserved(lang string, name string) string {
+	lang = language(lang)
+	if !reservedWords[lang][name] {
+		return name
+	}
+	if lang == "rust" && !rustUnescapable[name] {
+		return "r#" + name
+	}
+	return name + "_"
+}
+
+// sortedKeys returns the keys of a map in a stable order, sorting strings
+// and numbers by their value and all other keys by their string form
+func sortedKeys(m interface{}) ([]interface{}, error) {
+	value := reflect.ValueOf(m)
+	if value.Kind() != reflect.Map {
+		return nil, fmt.Errorf("sortedKeys expects a map, got %T", m)
+	}
+
+	keys := value.MapKeys()
+	sort.SliceStable(keys, func(i, j int) bool {
+		return lessValue(keys[i], keys[j])
+	})
+
+	out := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, key.Interface())
+	}
+	return out, nil
+}
+
+// lessValue orders map keys of the same kind by value and all other keys by
+// their string form
+func lessValue(a, b reflect.Value) bool {
+	for a.Kind() == reflect.Interface && !a.IsNil() {
+		a = a.Elem()
+	}
+	for b.Kind() == reflect.Interface && !b.IsNil() {
+		b = b.Elem()
+	}
+
+	switch {
+	case a.Kind() == reflect.String && b.Kind() == reflect.String:
+		return a.String() < b.String()
+	case isInt(a) && isInt(b):
+		return a.Int() < b.Int()
+	case isUint(a) && isUint(b):
+		return a.Uint() < b.Uint()
+	case isFloat(a) && isFloat(b):
+		return a.Float() < b.Float()
+	}
+	return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+}
+
+func isInt(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUint(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func isFloat(v reflect.Value) bool {
+	return v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64
+}
+
+// indentLines indents all non-empty lines of text by the given number of
+// spaces
+func indentLines(spaces int, text string) string {
+	return prefixLines(strings.Repeat(" ", spaces), text)
+}
+
+// prefixLines prefixes all lines of text, without adding trailing
+// whitespace to empty lines, e.g. to turn text into a comment
+func prefixLines(prefix string, text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if len(strings.TrimSpace(line)) != 0 {
+			lines[i] = prefix + line
+		} else if i != len(lines)-1 {
+			lines[i] = strings.TrimRight(prefix, " \t")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// dedent removes the leading whitespace common to all non-empty lines of
+// text
+func dedent(text string) string {
+	lines := strings.Split(text, "\n")
+
+	common := ""
+	first := true
+	for _, line := range lines {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			common, first = indent, false
+			continue
+		}
+		for !strings.HasPrefix(indent, common) {
+			common = common[:len(common)-1]
+		}
+	}
+
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, common)
+		if len(strings.TrimSpace(lines[i])) == 0 {
+			lines[i] = ""
+		}
+	}
+	return strings.Join(lines, "\n")
+}
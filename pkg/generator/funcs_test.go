@@ -0,0 +1,313 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/bradleyjkemp/cupaloy"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/knitcodegen/knit/pkg/loader"
+	"github.com/knitcodegen/knit/pkg/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	inputFileTypes               = "./testdata/inputs/types.yml"
+	inputTmplFileTypes           = "./testdata/templates/golden_types.tmpl"
+	inputTmplFileTypesJSONSchema = "./testdata/templates/golden_types_jsonschema.tmpl"
+
+	inputFileOpenAPIFuncs     = "./testdata/inputs/petstore.yml"
+	inputTmplFileOpenAPIFuncs = "./testdata/templates/golden_openapi_funcs.tmpl"
+)
+
+func Test_Casing(t *testing.T) {
+	cases := []struct {
+		in            string
+		words         []string
+		goName        string
+		goPrivateName string
+		tsName        string
+		pyName        string
+		rustName      string
+		kebabCase     string
+	}{
+		{
+			in:            "user_id",
+			words:         []string{"user", "id"},
+			goName:        "UserID",
+			goPrivateName: "userID",
+			tsName:        "userId",
+			pyName:        "user_id",
+			rustName:      "user_id",
+			kebabCase:     "user-id",
+		},
+		{
+			in:            "HTTPServerURL",
+			words:         []string{"HTTP", "Server", "URL"},
+			goName:        "HTTPServerURL",
+			goPrivateName: "httpServerURL",
+			tsName:        "httpServerUrl",
+			pyName:        "http_server_url",
+			rustName:      "http_server_url",
+			kebabCase:     "http-server-url",
+		},
+		{
+			in:            "userIds",
+			words:         []string{"user", "Ids"},
+			goName:        "UserIDs",
+			goPrivateName: "userIDs",
+			tsName:        "userIds",
+			pyName:        "user_ids",
+			rustName:      "user_ids",
+			kebabCase:     "user-ids",
+		},
+		{
+			in:            "APIsByUserIDs",
+			words:         []string{"APIs", "By", "User", "IDs"},
+			goName:        "APIsByUserIDs",
+			goPrivateName: "apisByUserIDs",
+			tsName:        "apisByUserIds",
+			pyName:        "apis_by_user_ids",
+			rustName:      "apis_by_user_ids",
+			kebabCase:     "apis-by-user-ids",
+		},
+		{
+			in:            "2fa-code",
+			words:         []string{"2fa", "code"},
+			goName:        "X2faCode",
+			goPrivateName: "_2faCode",
+			tsName:        "_2faCode",
+			pyName:        "_2fa_code",
+			rustName:      "_2fa_code",
+			kebabCase:     "2fa-code",
+		},
+		{
+			in:            "type",
+			words:         []string{"type"},
+			goName:        "Type",
+			goPrivateName: "type_",
+			tsName:        "type",
+			pyName:        "type",
+			rustName:      "r#type",
+			kebabCase:     "type",
+		},
+		{
+			in:            "class",
+			words:         []string{"class"},
+			goName:        "Class",
+			goPrivateName: "class",
+			tsName:        "class_",
+			pyName:        "class_",
+			rustName:      "class",
+			kebabCase:     "class",
+		},
+		{
+			in:            "self",
+			words:         []string{"self"},
+			goName:        "Self",
+			goPrivateName: "self",
+			tsName:        "self",
+			pyName:        "self",
+			rustName:      "self_",
+			kebabCase:     "self",
+		},
+		{
+			in:            "utf8Value v2",
+			words:         []string{"utf8", "Value", "v2"},
+			goName:        "UTF8ValueV2",
+			goPrivateName: "utf8ValueV2",
+			tsName:        "utf8ValueV2",
+			pyName:        "utf8_value_v2",
+			rustName:      "utf8_value_v2",
+			kebabCase:     "utf8-value-v2",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.in, func(t *testing.T) {
+			assert.Equal(t, c.words, words(c.in))
+			assert.Equal(t, c.goName, goName(c.in))
+			assert.Equal(t, c.goPrivateName, goPrivateName(c.in))
+			assert.Equal(t, c.tsName, tsName(c.in))
+			assert.Equal(t, c.pyName, pyName(c.in))
+			assert.Equal(t, c.rustName, rustName(c.in))
+			assert.Equal(t, c.kebabCase, kebabCase(c.in))
+		})
+	}
+
+	assert.Equal(t, "PetOwner", pascalCase("pet owner"))
+	assert.Equal(t, "PET_OWNER", screamingSnakeCase("petOwner"))
+	assert.Equal(t, "None_", pyClassName("none"))
+	assert.Equal(t, "Self_", rustTypeName("self"))
+	assert.True(t, isReserved("typescript", "enum"))
+	assert.False(t, isReserved("go", "enum"))
+	assert.Equal(t, "r#match", escapeReserved("rs", "match"))
+}
+
+func Test_Inflection(t *testing.T) {
+	cases := []struct {
+		singular string
+		plural   string
+	}{
+		{singular: "pet", plural: "pets"},
+		{singular: "Category", plural: "Categories"},
+		{singular: "key", plural: "keys"},
+		{singular: "address", plural: "addresses"},
+		{singular: "status", plural: "statuses"},
+		{singular: "box", plural: "boxes"},
+		{singular: "match", plural: "matches"},
+		{singular: "quiz", plural: "quizzes"},
+		{singular: "cause", plural: "causes"},
+		{singular: "size", plural: "sizes"},
+		{singular: "cache", plural: "caches"},
+		{singular: "movie", plural: "movies"},
+		{singular: "person", plural: "people"},
+		{singular: "Child", plural: "Children"},
+		{singular: "analysis", plural: "analyses"},
+		{singular: "leaf", plural: "leaves"},
+		{singular: "data", plural: "data"},
+		{singular: "USER", plural: "USERS"},
+		{singular: "petOwner", plural: "petOwners"},
+		{singular: "pet_category", plural: "pet_categories"},
+		{singular: "userID", plural: "userIDs"},
+		{singular: "URL", plural: "URLs"},
+		{singular: "APIKey", plural: "APIKeys"},
+		{singular: "user_", plural: "users_"},
+		{singular: "__pet__", plural: "__pets__"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.singular, func(t *testing.T) {
+			assert.Equal(t, c.plural, pluralize(c.singular))
+			assert.Equal(t, c.singular, singularize(c.plural))
+		})
+	}
+
+	assert.Equal(t, "status", singularize("status"))
+	assert.Equal(t, "address", singularize("address"))
+}
+
+func Test_SortedKeys(t *testing.T) {
+	keys, err := sortedKeys(map[string]int{"b": 1, "c": 2, "a": 3})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "b", "c"}, keys)
+
+	keys, err = sortedKeys(map[int]bool{10: true, 2: true, -1: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{-1, 2, 10}, keys)
+
+	keys, err = sortedKeys(map[interface{}]bool{"b": true, 1: true, "a": true})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{1, "a", "b"}, keys)
+
+	_, err = sortedKeys([]string{"a"})
+	assert.EqualError(t, err, "sortedKeys expects a map, got []string")
+}
+
+func Test_Indentation(t *testing.T) {
+	assert.Equal(t, "    a\n\n      b\n", indentLines(4, "a\n\n  b\n"))
+	assert.Equal(t, "// a\n//\n// b", prefixLines("// ", "a\n\nb"))
+	assert.Equal(t, "a\n  b\n\nc", dedent("    a\n      b\n  \n    c"))
+	assert.Equal(t, "a\nb", dedent("\ta\n\tb"))
+}
+
+func Test_TypeMappers(t *testing.T) {
+	nullable := &openapi3.Schema{Type: "integer", Format: "int64", Nullable: true}
+	cases := []struct {
+		name   string
+		schema interface{}
+		goType string
+		tsType string
+	}{
+		{name: "ref", schema: &openapi3.SchemaRef{Ref: "#/components/schemas/pet_owner"}, goType: "PetOwner", tsType: "PetOwner"},
+		{name: "file ref", schema: &openapi3.SchemaRef{Ref: "./components/pet.yml"}, goType: "Pet", tsType: "Pet"},
+		{name: "nullable", schema: nullable, goType: "*int64", tsType: "number | null"},
+		{name: "date-time", schema: openapi3.NewDateTimeSchema(), goType: "time.Time", tsType: "string"},
+		{name: "binary", schema: openapi3.NewBytesSchema(), goType: "[]byte", tsType: "string"},
+		{name: "enum", schema: openapi3.NewStringSchema().WithEnum("a", "b"), goType: "string", tsType: `"a" | "b"`},
+		{name: "array", schema: openapi3.NewArraySchema().WithItems(openapi3.NewStringSchema().WithEnum("a", "b")), goType: "[]string", tsType: `("a" | "b")[]`},
+		{name: "map", schema: openapi3.NewObjectSchema().WithAdditionalProperties(openapi3.NewFloat64Schema()), goType: "map[string]float64", tsType: "Record<string, number>"},
+		{name: "object", schema: openapi3.NewObjectSchema(), goType: "map[string]interface{}", tsType: "Record<string, unknown>"},
+		{name: "any", schema: &openapi3.Schema{}, goType: "interface{}", tsType: "unknown"},
+		{name: "json schema ref", schema: &loader.JSONSchema{Ref: &loader.JSONSchema{Name: "Tag"}}, goType: "Tag", tsType: "Tag"},
+		{name: "json schema types", schema: &loader.JSONSchema{Type: "string", Types: []string{"string", "integer"}}, goType: "interface{}", tsType: "unknown"},
+		{name: "json schema property", schema: &loader.JSONSchemaProperty{JSONSchema: &loader.JSONSchema{Type: "boolean", Nullable: true}}, goType: "*bool", tsType: "boolean | null"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			goType, err := goType(c.schema)
+			assert.NoError(t, err)
+			assert.Equal(t, c.goType, goType)
+
+			tsType, err := tsType(c.schema)
+			assert.NoError(t, err)
+			assert.Equal(t, c.tsType, tsType)
+		})
+	}
+
+	_, err := goType("string")
+	assert.EqualError(t, err, "unsupported schema type string")
+}
+
+func Test_FuncMap(t *testing.T) {
+	cases := []struct {
+		name       string
+		loaderType string
+		input      string
+		template   string
+	}{
+		{name: "openapi3", loaderType: "openapi3", input: inputFileTypes, template: inputTmplFileTypes},
+		{name: "jsonschema", loaderType: "jsonschema", input: inputFileJSONSchema, template: inputTmplFileTypesJSONSchema},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gen, err := New(
+				&parser.Option{Type: Input, Value: c.input},
+				&parser.Option{Type: Loader, Value: c.loaderType},
+				&parser.Option{Type: Template, Value: c.template},
+			)
+			assert.NoError(t, err)
+
+			codegen, err := gen.Generate()
+			assert.NoError(t, err)
+			cupaloy.SnapshotT(t, codegen)
+		})
+	}
+}
+
+func Test_OpenAPIFuncs(t *testing.T) {
+	gen, err := New(
+		&parser.Option{Type: Input, Value: inputFileOpenAPIFuncs},
+		&parser.Option{Type: Loader, Value: "openapi3"},
+		&parser.Option{Type: Template, Value: inputTmplFileOpenAPIFuncs},
+	)
+	assert.NoError(t, err)
+
+	codegen, err := gen.Generate()
+	assert.NoError(t, err)
+	cupaloy.SnapshotT(t, codegen)
+
+	name, err := refNameOf("./components/pet.yml#/Pet")
+	assert.NoError(t, err)
+	assert.Equal(t, "Pet", name)
+
+	name, err = refNameOf(&openapi3.ParameterRef{Ref: "#/components/parameters/PetID"})
+	assert.NoError(t, err)
+	assert.Equal(t, "PetID", name)
+
+	name, err = refNameOf(&openapi3.SchemaRef{Value: openapi3.NewStringSchema()})
+	assert.NoError(t, err)
+	assert.Equal(t, "", name)
+
+	_, err = refNameOf(42)
+	assert.EqualError(t, err, "unsupported reference type int")
+
+	_, err = requestBody("getPet")
+	assert.EqualError(t, err, "unsupported operation type string")
+
+	_, err = schemaName(&loader.JSONSchema{})
+	assert.EqualError(t, err, "unsupported schema type *loader.JSONSchema")
+
+	assert.Empty(t, operations(nil))
+}
@@ -1,13 +1,18 @@
 package generator
 
 import (
-	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
-	"text/template"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
-	"github.com/Masterminds/sprig"
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/knitcodegen/knit/pkg/loader"
 	"github.com/knitcodegen/knit/pkg/parser"
 
@@ -19,40 +24,143 @@ type Generator interface {
 	Validate() error
 	// Generate runs the code generator and returns the generated code block
 	Generate() (string, error)
+	// Dependencies returns the files read by the generator
+	Dependencies() []Dependency
+	// Fingerprint returns a hash of everything that determines the
+	// generated code block, including the contents of all files read
+	Fingerprint() (string, error)
+}
+
+// Dependency is a file read by a generator while generating code
+type Dependency struct {
+	// Type is the type of option the file was configured with
+	Type OptionType
+	// Path is the absolute path to the file
+	Path string
+}
+
+// Config holds settings shared by all generators of a knit run
+type Config struct {
+	// Loaders maps custom loader names to registered loader types
+	Loaders map[string]string
+	// Registry holds the loader types generators can use. Defaults to
+	// loader.DefaultRegistry if nil.
+	Registry *loader.Registry
+	// Engines maps the names of custom template engines to their
+	// implementation, taking precedence over the built-in engines
+	Engines map[string]TemplateEngine
+	// TemplatePaths are directories searched for template files that cannot
+	// be found relative to the working directory
+	TemplatePaths []string
+	// Partials are patterns of template files parsed along with the
+	// template of every generator
+	Partials []string
+	// Schemas caches the schemas loaded from input files, if set
+	Schemas *SchemaCache
+	// ImportPaths are directories searched for files imported by inputs
+	ImportPaths []string
+	// RefRoot is the directory files referenced by inputs are restricted
+	// to, if set
+	RefRoot string
+	// Verbose tells generators to log more output
+	Verbose bool
 }
 
 type generator struct {
+	// cfg holds the settings shared with other generators
+	cfg *Config
 	// Options represent the parser options used to construct this generator
 	Options []*parser.Option
 	// LoaderType is the string representation of the loader type
 	LoaderType string
 	// InputFile is the fully resolved path to the input file, if provided
 	InputFile *string
+	// Inputs are the named inputs, passed to the template as a map keyed
+	// by their names instead of the single input
+	Inputs []*namedInput
 	// InputLiteral is the provided input literal OR the loaded InputFile
 	InputLiteral string
 	// TemplateFile is the fully resolved path to the template file, if provided
 	TemplateFile *string
 	// TemplateLiteral is the provided template literal OR the loaded TemplateFile
 	TemplateLiteral string
+	// Includes are the fully resolved paths to the template files parsed
+	// along with the template
+	Includes []string
+	// includeLiterals are the contents of the include files, once loaded
+	includeLiterals []string
+	// EngineType is the name of the template engine, text/template if empty
+	EngineType string
+	// ImportPaths are the fully resolved directories searched for files
+	// imported by the input
+	ImportPaths []string
+	// Operations are the fully resolved paths to documents holding GraphQL
+	// operations
+	Operations []string
+	// Dialect is the dialect of SQL inputs
+	Dialect string
+	// Delimiter separates the fields of CSV inputs
+	Delimiter string
+	// inputModTime is the modification time of the input file, stat'ed
+	// before the file is read
+	inputModTime time.Time
+	// loaded is set once the input and template files have been read
+	loaded bool
 }
 
+// namedInput is an input bound to a name in the template data. It is
+// loaded by a generator of its own sharing the options of the code block.
+type namedInput struct {
+	Name string
+	gen  *generator
+}
+
+// namedInputPattern matches the values of named input options, e.g.
+// spec=./openapi.yml openapi3
+var namedInputPattern = regexp.MustCompile(`^(\w+)=(.*)$`)
+
 type OptionType = string
 
 const (
-	Input    OptionType = "input"
-	Loader   OptionType = "loader"
-	Template OptionType = "template"
+	Input      OptionType = "input"
+	Loader     OptionType = "loader"
+	Template   OptionType = "template"
+	ImportPath OptionType = "import_path"
+	Operations OptionType = "operations"
+	Dialect    OptionType = "dialect"
+	Delimiter  OptionType = "delimiter"
+	Include    OptionType = "include"
+	Engine     OptionType = "engine"
+
+	// Import is not an option but the type of dependencies on files that
+	// are imported by an input file
+	Import OptionType = "import"
 )
 
+// New creates a generator from the given options using the default config
 func New(opts ...*parser.Option) (Generator, error) {
+	return NewWithConfig(&Config{}, opts...)
+}
+
+// NewWithConfig creates a generator from the given options using the
+// provided config
+func NewWithConfig(cfg *Config, opts ...*parser.Option) (Generator, error) {
 	gen := &generator{
+		cfg:     cfg,
 		Options: opts,
 	}
 
 	for _, opt := range opts {
 		switch opt.Type {
 		case Input:
-			if len(opt.Literal) != 0 {
+			if m := namedInputPattern.FindStringSubmatch(strings.TrimSpace(opt.Value)); m != nil {
+				in, err := gen.newNamedInput(m[1], m[2], opt.Literal)
+				if err != nil {
+					return nil, err
+				}
+
+				gen.Inputs = append(gen.Inputs, in)
+			} else if len(opt.Literal) != 0 {
 				gen.InputFile = nil
 				gen.InputLiteral = opt.Literal
 
@@ -71,12 +179,39 @@ func New(opts ...*parser.Option) (Generator, error) {
 			}
 		case Loader:
 			gen.LoaderType = opt.Value
+		case ImportPath:
+			path, err := filepath.Abs(opt.Value)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to resolve absolute path to import path")
+			}
+
+			gen.ImportPaths = append(gen.ImportPaths, path)
+		case Operations:
+			path, err := filepath.Abs(opt.Value)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to resolve absolute path to operations file")
+			}
+
+			gen.Operations = append(gen.Operations, path)
+		case Dialect:
+			gen.Dialect = opt.Value
+		case Delimiter:
+			gen.Delimiter = opt.Value
+		case Engine:
+			gen.EngineType = opt.Value
+		case Include:
+			paths, err := gen.resolveInclude(opt.Value)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to resolve include")
+			}
+
+			gen.Includes = append(gen.Includes, paths...)
 		case Template:
 			if len(opt.Literal) != 0 {
 				gen.TemplateFile = nil
 				gen.TemplateLiteral = opt.Literal
 			} else {
-				path, err := filepath.Abs(opt.Value)
+				path, err := gen.resolveTemplate(opt.Value)
 				if err != nil {
 					return nil, errors.Wrap(err, "failed to resolve absolute path to template file")
 				}
@@ -86,32 +221,346 @@ func New(opts ...*parser.Option) (Generator, error) {
 		}
 	}
 
+	partials, err := gen.resolvePartials()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve partials")
+	}
+	gen.Includes = uniquePaths(append(partials, gen.Includes...))
+
+	// named inputs share the options of the code block, which may follow
+	// the input options
+	for _, in := range gen.Inputs {
+		if len(in.gen.LoaderType) == 0 {
+			in.gen.LoaderType = gen.LoaderType
+		}
+		in.gen.ImportPaths = gen.ImportPaths
+		in.gen.Operations = gen.Operations
+		in.gen.Dialect = gen.Dialect
+		in.gen.Delimiter = gen.Delimiter
+	}
+
 	return gen, nil
 }
 
-func createLoader(loaderType string) (loader.SchemaLoader, error) {
-	switch loaderType {
-	case "yml":
-		fallthrough
-	case "yaml":
-		return &loader.YamlLoader{}, nil
-	case "json":
-		return &loader.JsonLoader{}, nil
-	case "graphql":
-		return &loader.GraphqlLoader{}, nil
-	case "openapi3":
-		return &loader.OpenAPI3Loader{}, nil
+// newNamedInput creates a named input from the value of an input option
+// following the name, which is the path to the input file optionally
+// followed by the loader type, or the loader type of an input literal
+func (gen *generator) newNamedInput(name string, value string, literal string) (*namedInput, error) {
+	for _, in := range gen.Inputs {
+		if in.Name == name {
+			return nil, fmt.Errorf("duplicate input %s", name)
+		}
+	}
+
+	in := &namedInput{
+		Name: name,
+		gen:  &generator{cfg: gen.cfg},
+	}
+
+	fields := strings.Fields(value)
+	if len(literal) != 0 {
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("failed to determine loader type from literal of input %s", name)
+		}
+
+		in.gen.InputLiteral = literal
+		in.gen.LoaderType = fields[0]
+		return in, nil
+	}
+
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("invalid input %s, expected a path and an optional loader type", name)
+	}
+
+	path, err := filepath.Abs(fields[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve absolute path to input file")
+	}
+
+	in.gen.InputFile = &path
+	if len(fields) == 2 {
+		in.gen.LoaderType = fields[1]
+	}
+	return in, nil
+}
+
+// inputs returns the generators loading the inputs of the code block,
+// either the generator itself or those of the named inputs
+func (gen *generator) inputs() []*generator {
+	if len(gen.Inputs) == 0 {
+		return []*generator{gen}
+	}
+
+	inputs := make([]*generator, 0, len(gen.Inputs))
+	for _, in := range gen.Inputs {
+		inputs = append(inputs, in.gen)
 	}
-	return nil, fmt.Errorf("undefined loader type %s", loaderType)
+	return inputs
 }
 
-func (gen *generator) Validate() error {
-	if len(gen.LoaderType) == 0 {
-		return errors.New("missing loader type")
+// resolveTemplate returns the absolute path to a template file. Relative
+// paths that do not exist in the working directory are looked up in the
+// configured template paths.
+func (gen *generator) resolveTemplate(path string) (string, error) {
+	if !filepath.IsAbs(path) && gen.cfg != nil {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			for _, dir := range gen.cfg.TemplatePaths {
+				candidate := filepath.Join(dir, path)
+				if _, err := os.Stat(candidate); err == nil {
+					return filepath.Abs(candidate)
+				}
+			}
+		}
 	}
 
-	if len(gen.InputLiteral) == 0 {
-		return errors.New("missing input")
+	return filepath.Abs(path)
+}
+
+// resolveInclude returns the absolute paths to the template files matching
+// an include pattern. Relative patterns matching no files in the working
+// directory are matched in the configured template paths.
+func (gen *generator) resolveInclude(pattern string) ([]string, error) {
+	paths, err := globFiles(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(paths) == 0 && !filepath.IsAbs(pattern) && gen.cfg != nil {
+		for _, dir := range gen.cfg.TemplatePaths {
+			paths, err = globFiles(filepath.Join(dir, pattern))
+			if err != nil {
+				return nil, err
+			}
+			if len(paths) != 0 {
+				break
+			}
+		}
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("pattern %s matches no template files", pattern)
+	}
+	return paths, nil
+}
+
+// resolvePartials returns the absolute paths to the template files matching
+// the configured partials patterns
+func (gen *generator) resolvePartials() ([]string, error) {
+	if gen.cfg == nil {
+		return nil, nil
+	}
+
+	paths := make([]string, 0)
+	for _, pattern := range gen.cfg.Partials {
+		matches, err := globFiles(pattern)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// globFiles returns the sorted absolute paths to the regular files matching
+// a pattern, which may use ** to match any number of directories
+func globFiles(pattern string) ([]string, error) {
+	base, rest := doublestar.SplitPattern(filepath.ToSlash(filepath.Clean(pattern)))
+	if !doublestar.ValidatePattern(rest) {
+		return nil, fmt.Errorf("invalid pattern %s", pattern)
+	}
+
+	matches, err := doublestar.Glob(os.DirFS(filepath.FromSlash(base)), rest)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to match pattern %s", pattern)
+	}
+
+	paths := make([]string, 0, len(matches))
+	for _, match := range matches {
+		path, err := filepath.Abs(filepath.Join(filepath.FromSlash(base), filepath.FromSlash(match)))
+		if err != nil {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// uniquePaths removes all but the first occurrence of each path
+func uniquePaths(paths []string) []string {
+	seen := make(map[string]bool, len(paths))
+	unique := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if !seen[path] {
+			seen[path] = true
+			unique = append(unique, path)
+		}
+	}
+	return unique
+}
+
+// importPaths returns the directories searched for files imported by the
+// input. The directory of the input file, or the working directory for
+// literals, is searched first.
+func (gen *generator) importPaths() []string {
+	paths := []string{"."}
+	if gen.InputFile != nil {
+		paths = []string{filepath.Dir(*gen.InputFile)}
+	}
+
+	paths = append(paths, gen.ImportPaths...)
+	if gen.cfg != nil {
+		paths = append(paths, gen.cfg.ImportPaths...)
+	}
+
+	return paths
+}
+
+// refRoot returns the directory files referenced by the input are
+// restricted to, if any
+func (gen *generator) refRoot() string {
+	if gen.cfg != nil {
+		return gen.cfg.RefRoot
+	}
+	return ""
+}
+
+// resolveLoaderType returns the registered loader type of the configured
+// loader type
+func (gen *generator) resolveLoaderType() string {
+	if gen.cfg != nil {
+		if builtin, ok := gen.cfg.Loaders[gen.LoaderType]; ok {
+			return builtin
+		}
+	}
+	return gen.LoaderType
+}
+
+// registry returns the registry loaders are created from
+func (gen *generator) registry() *loader.Registry {
+	if gen.cfg != nil && gen.cfg.Registry != nil {
+		return gen.cfg.Registry
+	}
+	return loader.DefaultRegistry
+}
+
+// createLoader creates the loader for the configured loader type. Custom
+// loader names are resolved to their registered loader type first.
+func (gen *generator) createLoader() (loader.SchemaLoader, error) {
+	loaderType := gen.resolveLoaderType()
+
+	factory, ok := gen.registry().Lookup(loaderType)
+	if !ok {
+		return nil, fmt.Errorf("undefined loader type %s", loaderType)
+	}
+
+	return factory(loader.Options{
+		ImportPaths: gen.importPaths(),
+		Operations:  gen.Operations,
+		Dialect:     gen.Dialect,
+		Delimiter:   gen.Delimiter,
+		RefRoot:     gen.refRoot(),
+	}), nil
+}
+
+func (gen *generator) Dependencies() []Dependency {
+	deps := make([]Dependency, 0, 2)
+
+	for _, in := range gen.inputs() {
+		if in.InputFile != nil {
+			deps = append(deps, Dependency{
+				Type: Input,
+				Path: *in.InputFile,
+			})
+		}
+	}
+
+	if gen.TemplateFile != nil {
+		deps = append(deps, Dependency{
+			Type: Template,
+			Path: *gen.TemplateFile,
+		})
+	}
+
+	for _, path := range gen.Includes {
+		deps = append(deps, Dependency{
+			Type: Include,
+			Path: path,
+		})
+	}
+
+	for _, path := range gen.Operations {
+		deps = append(deps, Dependency{
+			Type: Operations,
+			Path: path,
+		})
+	}
+
+	// imports can only be resolved if the input can be read, any errors
+	// are reported once the generator runs
+	for _, in := range gen.inputs() {
+		imports, _ := in.resolveImports()
+		for _, path := range imports {
+			deps = append(deps, Dependency{
+				Type: Import,
+				Path: path,
+			})
+		}
+	}
+
+	return deps
+}
+
+// resolveImports returns the paths of all files imported by the input, if
+// the configured loader supports imports
+func (gen *generator) resolveImports() ([]string, error) {
+	// the loader type may be inferred from the input file
+	err := gen.load()
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := gen.createLoader()
+	if err != nil {
+		return nil, nil
+	}
+
+	if resolver, ok := l.(loader.FileImportResolver); ok && gen.InputFile != nil {
+		imports, err := resolver.ResolveFileImports(*gen.InputFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to resolve imports")
+		}
+		return imports, nil
+	}
+
+	resolver, ok := l.(loader.ImportResolver)
+	if !ok {
+		return nil, nil
+	}
+
+	imports, err := resolver.ResolveImports([]byte(gen.InputLiteral))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve imports")
+	}
+
+	return imports, nil
+}
+
+func (gen *generator) Validate() error {
+	if len(gen.Inputs) != 0 {
+		if gen.InputFile != nil || len(gen.InputLiteral) != 0 {
+			return errors.New("named inputs cannot be combined with an unnamed input")
+		}
+
+		for _, in := range gen.Inputs {
+			if err := in.gen.validateInput(); err != nil {
+				return errors.Wrapf(err, "invalid input %s", in.Name)
+			}
+		}
+	} else if err := gen.validateInput(); err != nil {
+		return err
 	}
 
 	if len(gen.TemplateLiteral) == 0 {
@@ -121,53 +570,281 @@ func (gen *generator) Validate() error {
 	return nil
 }
 
-func (gen *generator) Generate() (string, error) {
-	if gen.InputFile != nil {
+// validateInput ensures the input and its loader type are configured
+func (gen *generator) validateInput() error {
+	if len(gen.LoaderType) == 0 {
+		if gen.InputFile != nil {
+			return fmt.Errorf("missing loader type, failed to infer it from input file %s", filepath.Base(*gen.InputFile))
+		}
+		return errors.New("missing loader type")
+	}
+
+	if len(gen.InputLiteral) == 0 && !gen.inputIsDir() {
+		return errors.New("missing input")
+	}
+
+	return nil
+}
+
+// inputIsDir reports whether the input file is a directory, which only
+// loaders loading files from their location can load
+func (gen *generator) inputIsDir() bool {
+	if gen.InputFile == nil {
+		return false
+	}
+	info, err := os.Stat(*gen.InputFile)
+	return err == nil && info.IsDir()
+}
+
+// load reads the configured input and template files, if any
+func (gen *generator) load() error {
+	if gen.loaded {
+		return nil
+	}
+
+	if gen.InputFile != nil && !gen.inputIsDir() {
+		// the schema cache is keyed on the modification time of the content
+		// read, so the file is stat'ed first
+		info, err := os.Stat(*gen.InputFile)
+		if err != nil {
+			return errors.Wrap(err, "failed to load input file")
+		}
+		gen.inputModTime = info.ModTime()
+
 		byt, err := os.ReadFile(*gen.InputFile)
 		if err != nil {
-			return "", errors.Wrap(err, "failed to load input file")
+			return errors.Wrap(err, "failed to load input file")
 		}
 
 		gen.InputLiteral = string(byt)
+
+		if len(gen.LoaderType) == 0 {
+			gen.LoaderType = inferLoaderType(*gen.InputFile, gen.InputLiteral)
+			if len(gen.LoaderType) != 0 && gen.cfg != nil && gen.cfg.Verbose {
+				log.Printf("knit inferred loader %s for input file: %s", gen.LoaderType, *gen.InputFile)
+			}
+		}
+	}
+
+	if gen.InputFile != nil && len(gen.LoaderType) == 0 && gen.inputIsDir() {
+		gen.LoaderType = inferDirLoaderType(*gen.InputFile)
+		if len(gen.LoaderType) != 0 && gen.cfg != nil && gen.cfg.Verbose {
+			log.Printf("knit inferred loader %s for input directory: %s", gen.LoaderType, *gen.InputFile)
+		}
 	}
 
 	if gen.TemplateFile != nil {
 		byt, err := os.ReadFile(*gen.TemplateFile)
 		if err != nil {
-			return "", errors.Wrap(err, "failed to load template file")
+			return errors.Wrap(err, "failed to load template file")
 		}
 
 		gen.TemplateLiteral = string(byt)
 	}
 
-	err := gen.Validate()
+	gen.includeLiterals = make([]string, 0, len(gen.Includes))
+	for _, path := range gen.Includes {
+		byt, err := os.ReadFile(path)
+		if err != nil {
+			return errors.Wrap(err, "failed to load include file")
+		}
+
+		gen.includeLiterals = append(gen.includeLiterals, string(byt))
+	}
+
+	for _, in := range gen.Inputs {
+		if err := in.gen.load(); err != nil {
+			return errors.Wrapf(err, "failed to load input %s", in.Name)
+		}
+	}
+
+	gen.loaded = true
+	return nil
+}
+
+func (gen *generator) Fingerprint() (string, error) {
+	err := gen.load()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	write := func(s string) {
+		fmt.Fprintf(h, "%d:%s;", len(s), s)
+	}
+
+	for _, opt := range gen.Options {
+		write(opt.Type)
+		write(opt.Value)
+		write(opt.Literal)
+	}
+	for _, in := range gen.inputs() {
+		write(in.resolveLoaderType())
+		write(in.InputLiteral)
+	}
+	write(gen.TemplateLiteral)
+
+	for i, path := range gen.Includes {
+		write(path)
+		write(gen.includeLiterals[i])
+	}
+
+	for _, path := range gen.Operations {
+		byt, err := os.ReadFile(path)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to load operations file")
+		}
+		write(path)
+		write(string(byt))
+	}
+
+	for _, in := range gen.inputs() {
+		imports, err := in.resolveImports()
+		if err != nil {
+			return "", err
+		}
+		for _, path := range imports {
+			byt, err := os.ReadFile(path)
+			if err != nil {
+				return "", errors.Wrap(err, "failed to load imported file")
+			}
+			write(path)
+			write(string(byt))
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// loadSchema decodes the input into a schema object using the configured
+// loader. Input files are loaded from their location if the loader supports
+// it. Schemas of input files are shared through the schema cache, if one is
+// configured.
+func (gen *generator) loadSchema() (interface{}, error) {
+	l, err := gen.createLoader()
 	if err != nil {
-		return "", errors.Wrap(err, "failed to validate generator configuration")
+		return nil, errors.Wrap(err, "failed to create loader")
 	}
 
-	loader, err := createLoader(gen.LoaderType)
+	decode := func() (interface{}, error) {
+		var (
+			data interface{}
+			err  error
+		)
+		if fl, ok := l.(loader.FileLoader); ok && gen.InputFile != nil {
+			data, err = fl.LoadFromFile(*gen.InputFile)
+		} else if gen.inputIsDir() {
+			return nil, fmt.Errorf("loader %s cannot load directories", gen.LoaderType)
+		} else {
+			data, err = l.LoadFromData([]byte(gen.InputLiteral))
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decode input into schema object")
+		}
+		return data, nil
+	}
+
+	if gen.InputFile == nil || gen.cfg == nil || gen.cfg.Schemas == nil {
+		return decode()
+	}
+
+	modTime := gen.inputModTime
+	if gen.inputIsDir() {
+		info, err := os.Stat(*gen.InputFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load input file")
+		}
+		modTime = info.ModTime()
+	}
+
+	// loader options, import paths and operation documents change the
+	// loaded schema, so they are part of the cache key
+	key := strings.Join([]string{
+		gen.resolveLoaderType(),
+		gen.Dialect,
+		gen.Delimiter,
+		strings.Join(gen.ImportPaths, "\x01"),
+		strings.Join(gen.Operations, "\x01"),
+	}, "\x00")
+
+	// the schema is loaded again once an imported file or operation document
+	// changes
+	imports := func() ([]string, error) {
+		paths, err := gen.resolveImports()
+		if err != nil {
+			return nil, err
+		}
+		return append(paths, gen.Operations...), nil
+	}
+
+	return gen.cfg.Schemas.load(*gen.InputFile, key, modTime, imports, decode)
+}
+
+// templateData loads the data passed to the template, the schema of the
+// input or a map of the schemas of named inputs keyed by their names
+func (gen *generator) templateData() (interface{}, error) {
+	if len(gen.Inputs) == 0 {
+		return gen.loadSchema()
+	}
+
+	data := make(map[string]interface{}, len(gen.Inputs))
+	for _, in := range gen.Inputs {
+		schema, err := in.gen.loadSchema()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load input %s", in.Name)
+		}
+		data[in.Name] = schema
+	}
+	return data, nil
+}
+
+func (gen *generator) Generate() (string, error) {
+	err := gen.load()
 	if err != nil {
-		return "", errors.Wrap(err, "failed to create loader")
+		return "", err
 	}
 
-	data, err := loader.LoadFromData([]byte(gen.InputLiteral))
+	err = gen.Validate()
 	if err != nil {
-		return "", errors.Wrap(err, "failed to decode input into schema object")
+		return "", errors.Wrap(err, "failed to validate generator configuration")
 	}
 
-	tmpl, err := template.
-		New("knit").
-		Funcs(sprig.TxtFuncMap()).
-		Parse(gen.TemplateLiteral)
+	data, err := gen.templateData()
 	if err != nil {
-		return "", errors.Wrap(err, "failed to parse template")
+		return "", err
 	}
 
-	buf := &bytes.Buffer{}
-	err = tmpl.Execute(buf, data)
+	engine, err := gen.createEngine()
 	if err != nil {
-		return "", errors.Wrap(err, "failed to execute template")
+		return "", err
+	}
+
+	includes := make([]TemplateFile, 0, len(gen.Includes))
+	for i, path := range gen.Includes {
+		includes = append(includes, TemplateFile{
+			Name: filepath.Base(path),
+			Text: gen.includeLiterals[i],
+		})
 	}
 
-	return buf.String(), nil
+	return engine.Render(gen.TemplateLiteral, includes, data)
+}
+
+// createEngine returns the configured template engine. Custom engines take
+// precedence over built-in engines of the same name.
+func (gen *generator) createEngine() (TemplateEngine, error) {
+	engineType := gen.EngineType
+	if len(engineType) == 0 {
+		engineType = "text"
+	}
+
+	if gen.cfg != nil {
+		if engine, ok := gen.cfg.Engines[engineType]; ok {
+			return engine, nil
+		}
+	}
+	if engine, ok := engines[engineType]; ok {
+		return engine, nil
+	}
+	return nil, fmt.Errorf("undefined template engine %s", engineType)
 }
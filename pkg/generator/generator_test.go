@@ -1,10 +1,17 @@
 package generator
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/bradleyjkemp/cupaloy"
+	"github.com/knitcodegen/knit/pkg/loader"
+	"github.com/knitcodegen/knit/pkg/parser"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -15,9 +22,49 @@ var (
 	inputFileJson    = "./testdata/inputs/golden.json"
 	inputFileYaml    = "./testdata/inputs/golden.yml"
 	inputFileGraphql = "./testdata/inputs/golden.graphql"
+	inputFileProto   = "./testdata/inputs/golden.proto"
 
 	inputTmplFileGolden        = "./testdata/templates/golden.tmpl"
 	inputTmplFileGoldenGraphql = "./testdata/templates/golden_graphql.tmpl"
+	inputTmplFileGoldenProto   = "./testdata/templates/golden_protobuf.tmpl"
+
+	inputFileGraphqlSchema       = "./testdata/inputs/golden_schema.graphql"
+	inputTmplFileGraphqlSchema   = "./testdata/templates/golden_graphql_schema.tmpl"
+	operationsFileGraphql        = "./testdata/operations/users.graphql"
+	operationsFileGraphqlInvalid = "./testdata/operations/invalid.graphql"
+
+	inputFileSwagger2    = "./testdata/inputs/golden_swagger2.yml"
+	inputFileOpenAPI31   = "./testdata/inputs/golden_openapi31.yml"
+	inputTmplFileOpenAPI = "./testdata/templates/golden_openapi.tmpl"
+
+	inputFileOpenAPIRefs    = "./testdata/inputs/refs/openapi.yml"
+	inputFileOpenAPI31Refs  = "./testdata/inputs/refs/openapi31.yml"
+	inputFileSwagger2Refs   = "./testdata/inputs/refs/swagger2.yml"
+	inputFileOpenAPIOutside = "./testdata/inputs/refs/outside.yml"
+
+	inputFileJSONSchema     = "./testdata/inputs/jsonschema/pet.json"
+	inputTmplFileJSONSchema = "./testdata/templates/golden_jsonschema.tmpl"
+
+	inputFileSQLPostgres = "./testdata/inputs/sql/postgres.sql"
+	inputFileSQLite      = "./testdata/inputs/sql/sqlite.sql"
+	inputTmplFileSQL     = "./testdata/templates/golden_sql.tmpl"
+
+	inputFileToml     = "./testdata/inputs/golden.toml"
+	inputFileCSV      = "./testdata/inputs/csv/errors.csv"
+	inputFileTSV      = "./testdata/inputs/csv/zip.tsv"
+	inputFileDotenv   = "./testdata/inputs/golden.env"
+	inputTmplFileToml = "./testdata/templates/golden_toml.tmpl"
+	inputTmplFileCSV  = "./testdata/templates/golden_csv.tmpl"
+	inputTmplDotenv   = "./testdata/templates/golden_dotenv.tmpl"
+
+	inputFileOverrides  = "./testdata/inputs/overrides.yml"
+	inputTmplFileInputs = "./testdata/templates/golden_inputs.tmpl"
+
+	inputTmplFilePartials = "./testdata/templates/golden_partials.tmpl"
+	partialsPattern       = "./testdata/templates/partials/**/*.tmpl"
+
+	inputDirGo      = "./testdata/inputs/gopkg"
+	inputTmplFileGo = "./testdata/templates/golden_go.tmpl"
 )
 
 func fromFile(t *testing.T, filepath string) string {
@@ -109,6 +156,18 @@ func Test_Generate(t *testing.T) {
 				errMessage: "failed to create loader",
 			},
 		},
+		{
+			name: "handles custom loader name",
+			input: &generator{
+				cfg: &Config{
+					Loaders: map[string]string{"data": "json"},
+				},
+				LoaderType:      "data",
+				InputLiteral:    fromFile(t, inputFileJson),
+				TemplateLiteral: fromFile(t, inputTmplFileGolden),
+			},
+			want: want{},
+		},
 		{
 			name: "handles json input literal",
 			input: &generator{
@@ -154,6 +213,318 @@ func Test_Generate(t *testing.T) {
 			},
 			want: want{},
 		},
+		{
+			name: "handles graphql schema input file",
+			input: &generator{
+				LoaderType:   "graphql_schema",
+				InputFile:    &inputFileGraphqlSchema,
+				TemplateFile: &inputTmplFileGraphqlSchema,
+				Operations:   []string{operationsFileGraphql},
+			},
+			want: want{},
+		},
+		{
+			name: "handles invalid graphql operations",
+			input: &generator{
+				LoaderType:   "graphql_schema",
+				InputFile:    &inputFileGraphqlSchema,
+				TemplateFile: &inputTmplFileGraphqlSchema,
+				Operations:   []string{operationsFileGraphqlInvalid},
+			},
+			want: want{
+				err:        true,
+				errMessage: "failed to validate operations",
+			},
+		},
+		{
+			name: "handles swagger2 input file",
+			input: &generator{
+				LoaderType:   "swagger2",
+				InputFile:    &inputFileSwagger2,
+				TemplateFile: &inputTmplFileOpenAPI,
+			},
+			want: want{},
+		},
+		{
+			name: "handles openapi31 input file",
+			input: &generator{
+				LoaderType:   "openapi31",
+				InputFile:    &inputFileOpenAPI31,
+				TemplateFile: &inputTmplFileOpenAPI,
+			},
+			want: want{},
+		},
+		{
+			name: "handles invalid swagger2 input",
+			input: &generator{
+				LoaderType:      "swagger2",
+				InputLiteral:    "swagger: \"2.0\"\npaths: {}\n",
+				TemplateLiteral: fromFile(t, inputTmplFileOpenAPI),
+			},
+			want: want{
+				err:        true,
+				errMessage: "failed to validate swagger v2 spec",
+			},
+		},
+		{
+			name: "handles openapi3 input file with external refs",
+			input: &generator{
+				LoaderType:   "openapi3",
+				InputFile:    &inputFileOpenAPIRefs,
+				TemplateFile: &inputTmplFileOpenAPI,
+			},
+			want: want{},
+		},
+		{
+			name: "handles openapi31 input file with external refs",
+			input: &generator{
+				LoaderType:   "openapi31",
+				InputFile:    &inputFileOpenAPI31Refs,
+				TemplateFile: &inputTmplFileOpenAPI,
+			},
+			want: want{},
+		},
+		{
+			name: "handles swagger2 input file with external refs",
+			input: &generator{
+				LoaderType:   "swagger2",
+				InputFile:    &inputFileSwagger2Refs,
+				TemplateFile: &inputTmplFileOpenAPI,
+			},
+			want: want{},
+		},
+		{
+			name: "handles external refs outside of the ref root",
+			input: &generator{
+				cfg: &Config{
+					RefRoot: "./testdata/inputs/refs",
+				},
+				LoaderType:   "openapi3",
+				InputFile:    &inputFileOpenAPIOutside,
+				TemplateFile: &inputTmplFileOpenAPI,
+			},
+			want: want{
+				err:        true,
+				errMessage: "is outside of",
+			},
+		},
+		{
+			name: "handles jsonschema input file",
+			input: &generator{
+				LoaderType:   "jsonschema",
+				InputFile:    &inputFileJSONSchema,
+				TemplateFile: &inputTmplFileJSONSchema,
+			},
+			want: want{},
+		},
+		{
+			name: "handles invalid jsonschema input",
+			input: &generator{
+				LoaderType:      "jsonschema",
+				InputLiteral:    `{"type": 1}`,
+				TemplateLiteral: fromFile(t, inputTmplFileJSONSchema),
+			},
+			want: want{
+				err:        true,
+				errMessage: "failed to compile json schema",
+			},
+		},
+		{
+			name: "handles sql input file",
+			input: &generator{
+				LoaderType:   "sql",
+				InputFile:    &inputFileSQLPostgres,
+				TemplateFile: &inputTmplFileSQL,
+			},
+			want: want{},
+		},
+		{
+			name: "handles sqlite input file",
+			input: &generator{
+				LoaderType:   "sql",
+				Dialect:      "sqlite",
+				InputFile:    &inputFileSQLite,
+				TemplateFile: &inputTmplFileSQL,
+			},
+			want: want{},
+		},
+		{
+			name: "handles invalid sql input",
+			input: &generator{
+				LoaderType:      "sql",
+				InputLiteral:    "CREATE TABLE pets (id INT PRIMARY);",
+				TemplateLiteral: fromFile(t, inputTmplFileSQL),
+			},
+			want: want{
+				err:        true,
+				errMessage: "line 1: expected constraint of column id, found PRIMARY",
+			},
+		},
+		{
+			name: "handles unsupported sql dialect",
+			input: &generator{
+				LoaderType:      "sql",
+				Dialect:         "oracle",
+				InputLiteral:    "CREATE TABLE pets (id INT);",
+				TemplateLiteral: fromFile(t, inputTmplFileSQL),
+			},
+			want: want{
+				err:        true,
+				errMessage: "unsupported sql dialect oracle",
+			},
+		},
+		{
+			name: "handles toml input file",
+			input: &generator{
+				LoaderType:   "toml",
+				InputFile:    &inputFileToml,
+				TemplateFile: &inputTmplFileToml,
+			},
+			want: want{},
+		},
+		{
+			name: "handles invalid toml input",
+			input: &generator{
+				LoaderType:      "toml",
+				InputLiteral:    "title = ",
+				TemplateLiteral: fromFile(t, inputTmplFileToml),
+			},
+			want: want{
+				err:        true,
+				errMessage: "failed to unmarshal toml",
+			},
+		},
+		{
+			name: "handles csv input file",
+			input: &generator{
+				LoaderType:   "csv",
+				InputFile:    &inputFileCSV,
+				TemplateFile: &inputTmplFileCSV,
+			},
+			want: want{},
+		},
+		{
+			name: "handles csv delimiter",
+			input: &generator{
+				LoaderType:   "csv",
+				Delimiter:    "tab",
+				InputFile:    &inputFileTSV,
+				TemplateFile: &inputTmplFileCSV,
+			},
+			want: want{},
+		},
+		{
+			name: "handles csv with duplicate columns",
+			input: &generator{
+				LoaderType:      "csv",
+				InputLiteral:    "code,code\n1,2\n",
+				TemplateLiteral: fromFile(t, inputTmplFileCSV),
+			},
+			want: want{
+				err:        true,
+				errMessage: "failed to unmarshal csv: duplicate column code",
+			},
+		},
+		{
+			name: "handles invalid csv delimiter",
+			input: &generator{
+				LoaderType:      "csv",
+				Delimiter:       ";;",
+				InputLiteral:    "code\n1\n",
+				TemplateLiteral: fromFile(t, inputTmplFileCSV),
+			},
+			want: want{
+				err:        true,
+				errMessage: "invalid csv delimiter \";;\"",
+			},
+		},
+		{
+			name: "handles dotenv input file",
+			input: &generator{
+				LoaderType:   "dotenv",
+				InputFile:    &inputFileDotenv,
+				TemplateFile: &inputTmplDotenv,
+			},
+			want: want{},
+		},
+		{
+			name: "handles inferred loader type",
+			input: &generator{
+				InputFile:    &inputFileOpenAPI31,
+				TemplateFile: &inputTmplFileOpenAPI,
+			},
+			want: want{},
+		},
+		{
+			name: "handles input file of unknown type",
+			input: &generator{
+				InputFile:    &inputTmplFileOpenAPI,
+				TemplateFile: &inputTmplFileOpenAPI,
+			},
+			want: want{
+				err:        true,
+				errMessage: "missing loader type, failed to infer it from input file golden_openapi.tmpl",
+			},
+		},
+		{
+			name: "handles go package directory",
+			input: &generator{
+				LoaderType:   "go",
+				InputFile:    &inputDirGo,
+				TemplateFile: &inputTmplFileGo,
+			},
+			want: want{},
+		},
+		{
+			name: "handles go package directory with inferred loader type",
+			input: &generator{
+				InputFile:    &inputDirGo,
+				TemplateFile: &inputTmplFileGo,
+			},
+			want: want{},
+		},
+		{
+			name: "handles go package pattern literal",
+			input: &generator{
+				LoaderType:      "go",
+				InputLiteral:    inputDirGo,
+				TemplateLiteral: fromFile(t, inputTmplFileGo),
+			},
+			want: want{},
+		},
+		{
+			name: "handles directory input of file loaders",
+			input: &generator{
+				LoaderType:   "yaml",
+				InputFile:    &inputDirGo,
+				TemplateFile: &inputTmplFileGo,
+			},
+			want: want{
+				err:        true,
+				errMessage: "loader yaml cannot load directories",
+			},
+		},
+		{
+			name: "handles protobuf input file",
+			input: &generator{
+				LoaderType:   "protobuf",
+				InputFile:    &inputFileProto,
+				TemplateFile: &inputTmplFileGoldenProto,
+			},
+			want: want{},
+		},
+		{
+			name: "handles failure to resolve protobuf import",
+			input: &generator{
+				LoaderType:      "protobuf",
+				InputLiteral:    fromFile(t, inputFileProto),
+				TemplateLiteral: fromFile(t, inputTmplFileGoldenProto),
+			},
+			want: want{
+				err:        true,
+				errMessage: "failed to resolve import common/money.proto",
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -167,3 +538,568 @@ func Test_Generate(t *testing.T) {
 		})
 	}
 }
+
+func Test_NamedInputs(t *testing.T) {
+	abs := func(path string) string {
+		abs, err := filepath.Abs(path)
+		assert.NoError(t, err)
+		return abs
+	}
+
+	gen, err := New(
+		&parser.Option{Type: Input, Value: "spec=" + inputFileOpenAPIRefs + " openapi3"},
+		&parser.Option{Type: Input, Value: "overrides=" + inputFileOverrides},
+		&parser.Option{Type: Input, Value: "flags=toml", Literal: "[[flags]]\nname = \"beta\""},
+		&parser.Option{Type: Template, Value: inputTmplFileInputs},
+	)
+	assert.NoError(t, err)
+
+	codegen, err := gen.Generate()
+	assert.NoError(t, err)
+	cupaloy.SnapshotT(t, codegen)
+
+	assert.ElementsMatch(t, []Dependency{
+		{Type: Input, Path: abs(inputFileOpenAPIRefs)},
+		{Type: Input, Path: abs(inputFileOverrides)},
+		{Type: Template, Path: abs(inputTmplFileInputs)},
+		{Type: Import, Path: abs("./testdata/inputs/refs/components/pet.yml")},
+		{Type: Import, Path: abs("./testdata/inputs/refs/components/parameters.yml")},
+	}, gen.Dependencies())
+
+	_, err = New(
+		&parser.Option{Type: Input, Value: "spec=" + inputFileOpenAPIRefs},
+		&parser.Option{Type: Input, Value: "spec=" + inputFileOverrides},
+	)
+	assert.EqualError(t, err, "duplicate input spec")
+
+	gen, err = New(
+		&parser.Option{Type: Input, Value: inputFileYaml},
+		&parser.Option{Type: Input, Value: "overrides=" + inputFileOverrides},
+		&parser.Option{Type: Template, Value: inputTmplFileInputs},
+	)
+	assert.NoError(t, err)
+
+	_, err = gen.Generate()
+	assert.EqualError(t, err, "failed to validate generator configuration: named inputs cannot be combined with an unnamed input")
+
+	gen, err = New(
+		&parser.Option{Type: Input, Value: "spec=" + inputFileMissing},
+		&parser.Option{Type: Template, Value: inputTmplFileInputs},
+	)
+	assert.NoError(t, err)
+
+	_, err = gen.Generate()
+	assert.Error(t, err)
+}
+
+func Test_Includes(t *testing.T) {
+	abs := func(path string) string {
+		abs, err := filepath.Abs(path)
+		assert.NoError(t, err)
+		return abs
+	}
+
+	gen, err := New(
+		&parser.Option{Type: Input, Value: inputFileJson},
+		&parser.Option{Type: Include, Value: partialsPattern},
+		&parser.Option{Type: Template, Value: inputTmplFilePartials},
+	)
+	assert.NoError(t, err)
+
+	codegen, err := gen.Generate()
+	assert.NoError(t, err)
+	cupaloy.SnapshotT(t, codegen)
+
+	assert.Equal(t, []Dependency{
+		{Type: Input, Path: abs(inputFileJson)},
+		{Type: Template, Path: abs(inputTmplFilePartials)},
+		{Type: Include, Path: abs("./testdata/templates/partials/go/fields.tmpl")},
+		{Type: Include, Path: abs("./testdata/templates/partials/header.tmpl")},
+	}, gen.Dependencies())
+
+	// partials of the config and includes found in the template paths are
+	// parsed along with the template
+	cfgGen, err := NewWithConfig(&Config{
+		TemplatePaths: []string{"./testdata/templates"},
+		Partials:      []string{"./testdata/templates/partials/header.tmpl"},
+	},
+		&parser.Option{Type: Input, Value: inputFileJson},
+		&parser.Option{Type: Include, Value: "partials/go/*.tmpl"},
+		&parser.Option{Type: Template, Value: inputTmplFilePartials},
+	)
+	assert.NoError(t, err)
+
+	cfgCodegen, err := cfgGen.Generate()
+	assert.NoError(t, err)
+	assert.Equal(t, codegen, cfgCodegen)
+
+	// changes to include files change the fingerprint
+	header := filepath.Join(t.TempDir(), "header.tmpl")
+	assert.NoError(t, os.WriteFile(header, []byte(`{{ define "header" }}// v1{{ end }}`), 0644))
+
+	fingerprint := func() string {
+		gen, err := New(
+			&parser.Option{Type: Input, Value: inputFileJson},
+			&parser.Option{Type: Include, Value: header},
+			&parser.Option{Type: Template, Value: inputTmplFilePartials},
+		)
+		assert.NoError(t, err)
+		fp, err := gen.Fingerprint()
+		assert.NoError(t, err)
+		return fp
+	}
+
+	fp := fingerprint()
+	assert.NoError(t, os.WriteFile(header, []byte(`{{ define "header" }}// v2{{ end }}`), 0644))
+	assert.NotEqual(t, fp, fingerprint())
+
+	_, err = New(&parser.Option{Type: Include, Value: "./testdata/templates/partials/*.txt"})
+	assert.EqualError(t, err, "failed to resolve include: pattern ./testdata/templates/partials/*.txt matches no template files")
+}
+
+func Test_Engines(t *testing.T) {
+	cases := []struct {
+		engine   string
+		template string
+		include  string
+	}{
+		{engine: "text", template: "./testdata/templates/golden_partials.tmpl", include: "./testdata/templates/partials/**/*.tmpl"},
+		{engine: "html", template: "./testdata/templates/engines/golden_html.tmpl"},
+		{engine: "django", template: "./testdata/templates/engines/golden.django", include: "./testdata/templates/engines/*.django"},
+		{engine: "mustache", template: "./testdata/templates/engines/golden.mustache", include: "./testdata/templates/engines/*.mustache"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.engine, func(t *testing.T) {
+			opts := []*parser.Option{
+				{Type: Input, Value: "./testdata/inputs/engines.json"},
+				{Type: Engine, Value: c.engine},
+				{Type: Template, Value: c.template},
+			}
+			if len(c.include) != 0 {
+				opts = append(opts, &parser.Option{Type: Include, Value: c.include})
+			}
+
+			gen, err := New(opts...)
+			assert.NoError(t, err)
+
+			codegen, err := gen.Generate()
+			assert.NoError(t, err)
+			cupaloy.SnapshotT(t, codegen)
+		})
+	}
+
+	gen, err := NewWithConfig(&Config{
+		Engines: map[string]TemplateEngine{"upper": &upperEngine{}},
+	},
+		&parser.Option{Type: Input, Value: "json", Literal: `{"name": "knit"}`},
+		&parser.Option{Type: Engine, Value: "upper"},
+		&parser.Option{Type: Template, Literal: "hello"},
+	)
+	assert.NoError(t, err)
+
+	codegen, err := gen.Generate()
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO", codegen)
+
+	codegen, err = (&djangoEngine{}).Render(`{{ title }}{% include "a" %}`, []TemplateFile{{Name: "a", Text: "{{ title }}"}}, map[string]interface{}{"title": "<&>"})
+	assert.NoError(t, err)
+	assert.Equal(t, "<&><&>", codegen)
+
+	_, err = (&generator{
+		LoaderType:      "json",
+		EngineType:      "erb",
+		InputLiteral:    "{}",
+		TemplateLiteral: "hello",
+	}).Generate()
+	assert.EqualError(t, err, "undefined template engine erb")
+}
+
+func Test_RawMustache(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "variable", input: "{{ name }} {{name}}", want: "{{& name }} {{&name}}"},
+		{name: "unescaped variables", input: "{{{name}}} {{{ name }}} {{&name}}", want: "{{{name}}} {{{ name }}} {{&name}}"},
+		{name: "other tags", input: "{{#a}}{{^b}}{{/b}}{{/a}}{{! note }}{{> p}}", want: "{{#a}}{{^b}}{{/b}}{{/a}}{{! note }}{{> p}}"},
+		{name: "set delimiters", input: "{{=<% %>=}}<% name %>{{ x }}<%={{ }}=%>{{y}}", want: "{{=<% %>=}}<%& name %>{{ x }}<%={{ }}=%>{{&y}}"},
+		{name: "unmatched tag", input: "a {{ name", want: "a {{ name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, rawMustache(tt.input))
+		})
+	}
+}
+
+// upperEngine is a custom template engine rendering templates in upper case
+type upperEngine struct{}
+
+func (e *upperEngine) Render(tmpl string, includes []TemplateFile, data interface{}) (string, error) {
+	return strings.ToUpper(tmpl), nil
+}
+
+func Test_NewWithConfig(t *testing.T) {
+	gen, err := NewWithConfig(&Config{
+		TemplatePaths: []string{"./testdata/does_not_exist", "./testdata/templates"},
+	}, &parser.Option{
+		Type:  Template,
+		Value: "golden.tmpl",
+	})
+	assert.NoError(t, err)
+
+	path, err := filepath.Abs(inputTmplFileGolden)
+	assert.NoError(t, err)
+	assert.Equal(t, &path, gen.(*generator).TemplateFile)
+}
+
+func Test_InferLoaderType(t *testing.T) {
+	cases := []struct {
+		path string
+		want string
+	}{
+		{path: inputFileJson, want: "json"},
+		{path: inputFileYaml, want: "yaml"},
+		{path: inputFileToml, want: "toml"},
+		{path: inputFileCSV, want: "csv"},
+		{path: inputFileDotenv, want: "dotenv"},
+		{path: inputFileGraphql, want: "graphql"},
+		{path: operationsFileGraphql, want: "graphql"},
+		{path: inputFileProto, want: "protobuf"},
+		{path: inputFileSQLPostgres, want: "sql"},
+		{path: inputFileOpenAPIRefs, want: "openapi3"},
+		{path: inputFileOpenAPI31, want: "openapi31"},
+		{path: inputFileSwagger2, want: "swagger2"},
+		{path: inputFileJSONSchema, want: "json"},
+		{path: inputTmplFileGolden, want: ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			assert.Equal(t, c.want, inferLoaderType(c.path, fromFile(t, c.path)))
+		})
+	}
+
+	assert.Equal(t, "openapi3", inferLoaderType("spec.JSON", `{"openapi": "3.0.3"}`))
+	assert.Equal(t, "openapi31", inferLoaderType("spec.json", `{"info": {"openapi": "3.0.3"}, "openapi": 3.1}`))
+	assert.Equal(t, "swagger2", inferLoaderType("spec.json", `{"swagger": "2.0"}`))
+	assert.Equal(t, "json", inferLoaderType("spec.json", `{"info": {"openapi": "3.0.3"}, "paths": [{"swagger": "2.0"}]}`))
+	assert.Equal(t, "openapi31", inferLoaderType("spec.yml", "# spec\n---\ninfo:\n  swagger: 2.0\n\"openapi\": '3.1.0' # version\n"))
+	assert.Equal(t, "yaml", inferLoaderType("spec.yml", "info:\n  openapi: 3.0.3\n"))
+
+	// directories containing go files are go packages
+	assert.Equal(t, "go", inferDirLoaderType(inputDirGo))
+	assert.Equal(t, "", inferDirLoaderType("./testdata/inputs/refs"))
+}
+
+// upperLoader is a custom loader loading its input as upper case text
+type upperLoader struct {
+	delimiter string
+}
+
+func (l *upperLoader) LoadFromData(data []byte) (interface{}, error) {
+	return strings.Split(strings.ToUpper(string(data)), l.delimiter), nil
+}
+
+func Test_Registry(t *testing.T) {
+	registry := loader.NewRegistry()
+	registry.Register("upper", func(opts loader.Options) loader.SchemaLoader {
+		return &upperLoader{delimiter: opts.Delimiter}
+	})
+
+	gen, err := NewWithConfig(&Config{
+		Registry: registry,
+		Loaders:  map[string]string{"shout": "upper"},
+	},
+		&parser.Option{Type: Input, Value: "upper", Literal: "red,green"},
+		&parser.Option{Type: Delimiter, Value: ","},
+		&parser.Option{Type: Template, Literal: "{{ range . }}{{ . }};{{ end }}"},
+	)
+	assert.NoError(t, err)
+
+	codegen, err := gen.Generate()
+	assert.NoError(t, err)
+	assert.Equal(t, "RED;GREEN;", codegen)
+
+	gen, err = NewWithConfig(&Config{
+		Registry: registry,
+		Loaders:  map[string]string{"shout": "upper"},
+	},
+		&parser.Option{Type: Input, Value: "shout", Literal: "blue"},
+		&parser.Option{Type: Delimiter, Value: ","},
+		&parser.Option{Type: Template, Literal: "{{ . }}"},
+	)
+	assert.NoError(t, err)
+
+	codegen, err = gen.Generate()
+	assert.NoError(t, err)
+	assert.Equal(t, "[BLUE]", codegen)
+
+	// built-in loaders are registered with every registry
+	_, ok := registry.Lookup("openapi3")
+	assert.True(t, ok)
+
+	// other registries are not affected
+	_, ok = loader.Lookup("upper")
+	assert.False(t, ok)
+
+	_, err = (&generator{
+		LoaderType:      "upper",
+		InputLiteral:    "red",
+		TemplateLiteral: "{{ . }}",
+	}).Generate()
+	assert.EqualError(t, err, "failed to create loader: undefined loader type upper")
+}
+
+func Test_Fingerprint(t *testing.T) {
+	fingerprint := func(gen Generator) string {
+		fp, err := gen.Fingerprint()
+		assert.NoError(t, err)
+		return fp
+	}
+
+	json := &generator{
+		LoaderType:   "json",
+		InputFile:    &inputFileJson,
+		TemplateFile: &inputTmplFileGolden,
+	}
+	jsonLiteral := &generator{
+		LoaderType:      "json",
+		InputLiteral:    fromFile(t, inputFileJson),
+		TemplateLiteral: fromFile(t, inputTmplFileGolden),
+	}
+	yaml := &generator{
+		LoaderType:   "yaml",
+		InputFile:    &inputFileYaml,
+		TemplateFile: &inputTmplFileGolden,
+	}
+
+	assert.Equal(t, fingerprint(json), fingerprint(jsonLiteral))
+	assert.NotEqual(t, fingerprint(json), fingerprint(yaml))
+
+	_, err := (&generator{InputFile: &inputFileMissing}).Fingerprint()
+	assert.Error(t, err)
+}
+
+func Test_SchemaCache(t *testing.T) {
+	cache := NewSchemaCache()
+
+	var (
+		mu    sync.Mutex
+		loads int
+	)
+	load := func() (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		loads++
+		return loads, nil
+	}
+
+	modTime := time.Now()
+	noImports := func() ([]string, error) {
+		return nil, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := cache.load("/spec.yml", "openapi3", modTime, noImports, load)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, data)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, loads)
+
+	data, err := cache.load("/spec.yml", "yaml", modTime, noImports, load)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, data)
+
+	data, err = cache.load("/spec.yml", "openapi3", modTime.Add(time.Second), noImports, load)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, data)
+
+	// entries are loaded again once an imported file changes
+	imported := filepath.Join(t.TempDir(), "common.proto")
+	err = os.WriteFile(imported, []byte("syntax = \"proto3\";"), 0644)
+	assert.NoError(t, err)
+	imports := func() ([]string, error) {
+		return []string{imported}, nil
+	}
+
+	data, err = cache.load("/api.proto", "proto", modTime, imports, load)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, data)
+
+	data, err = cache.load("/api.proto", "proto", modTime, imports, load)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, data)
+
+	err = os.Chtimes(imported, modTime, modTime.Add(time.Minute))
+	assert.NoError(t, err)
+
+	data, err = cache.load("/api.proto", "proto", modTime, imports, load)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, data)
+}
+
+func Test_SchemaCache_Mutation(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "in.json")
+	err := os.WriteFile(input, []byte(`{"name": "knit", "tags": {"go": true}}`), 0644)
+	assert.NoError(t, err)
+
+	cfg := &Config{Schemas: NewSchemaCache()}
+	generate := func(tmpl string) string {
+		gen, err := NewWithConfig(cfg,
+			&parser.Option{Type: Input, Value: input},
+			&parser.Option{Type: Template, Literal: tmpl},
+		)
+		assert.NoError(t, err)
+		codegen, err := gen.Generate()
+		assert.NoError(t, err)
+		return codegen
+	}
+
+	// templates changing their input do not change the input of others
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			generate(fmt.Sprintf(`{{ $_ := set . "n%d" "x" }}{{ $_ := set .tags "n%d" true }}{{ $_ := unset . "name" }}`, i, i))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, "2 1 knit", generate(`{{ len . }} {{ len .tags }} {{ .name }}`))
+}
+
+func Test_SchemaCache_ImportPaths(t *testing.T) {
+	cfg := &Config{Schemas: NewSchemaCache()}
+
+	schema := func(importPath string) interface{} {
+		gen, err := NewWithConfig(cfg,
+			&parser.Option{Type: Input, Value: inputFileProto},
+			&parser.Option{Type: Loader, Value: "protobuf"},
+			&parser.Option{Type: ImportPath, Value: importPath},
+			&parser.Option{Type: Template, Literal: "{{ . }}"},
+		)
+		assert.NoError(t, err)
+
+		g := gen.(*generator)
+		assert.NoError(t, g.load())
+		data, err := g.loadSchema()
+		assert.NoError(t, err)
+		return data
+	}
+
+	// generators with different import paths do not share schemas
+	a := schema("./testdata/inputs")
+	b := schema("./testdata/inputs/common")
+	assert.NotSame(t, a, b)
+	assert.Same(t, a, schema("./testdata/inputs"))
+}
+
+func Test_Dependencies(t *testing.T) {
+	abs := func(path string) string {
+		abs, err := filepath.Abs(path)
+		assert.NoError(t, err)
+		return abs
+	}
+
+	gen, err := New(
+		&parser.Option{Type: Input, Value: inputFileProto},
+		&parser.Option{Type: Loader, Value: "protobuf"},
+		&parser.Option{Type: Template, Value: inputTmplFileGoldenProto},
+	)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []Dependency{
+		{Type: Input, Path: abs(inputFileProto)},
+		{Type: Template, Path: abs(inputTmplFileGoldenProto)},
+		{Type: Import, Path: abs("./testdata/inputs/common/money.proto")},
+	}, gen.Dependencies())
+}
+
+func Test_Dependencies_Operations(t *testing.T) {
+	abs := func(path string) string {
+		abs, err := filepath.Abs(path)
+		assert.NoError(t, err)
+		return abs
+	}
+
+	gen, err := New(
+		&parser.Option{Type: Input, Value: inputFileGraphqlSchema},
+		&parser.Option{Type: Loader, Value: "graphql_schema"},
+		&parser.Option{Type: Operations, Value: operationsFileGraphql},
+		&parser.Option{Type: Template, Value: inputTmplFileGraphqlSchema},
+	)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []Dependency{
+		{Type: Input, Path: abs(inputFileGraphqlSchema)},
+		{Type: Template, Path: abs(inputTmplFileGraphqlSchema)},
+		{Type: Operations, Path: abs(operationsFileGraphql)},
+	}, gen.Dependencies())
+}
+
+func Test_Dependencies_Refs(t *testing.T) {
+	abs := func(path string) string {
+		abs, err := filepath.Abs(path)
+		assert.NoError(t, err)
+		return abs
+	}
+
+	refs := []Dependency{
+		{Type: Input, Path: abs(inputFileOpenAPIRefs)},
+		{Type: Template, Path: abs(inputTmplFileOpenAPI)},
+		{Type: Import, Path: abs("./testdata/inputs/refs/components/pet.yml")},
+		{Type: Import, Path: abs("./testdata/inputs/refs/components/parameters.yml")},
+	}
+
+	gen, err := New(
+		&parser.Option{Type: Input, Value: inputFileOpenAPIRefs},
+		&parser.Option{Type: Loader, Value: "openapi3"},
+		&parser.Option{Type: Template, Value: inputTmplFileOpenAPI},
+	)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, refs, gen.Dependencies())
+
+	// the openapi3 loader is inferred from the specification
+	gen, err = New(
+		&parser.Option{Type: Input, Value: inputFileOpenAPIRefs},
+		&parser.Option{Type: Template, Value: inputTmplFileOpenAPI},
+	)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, refs, gen.Dependencies())
+
+	gen, err = New(
+		&parser.Option{Type: Input, Value: inputFileSwagger2Refs},
+		&parser.Option{Type: Template, Value: inputTmplFileOpenAPI},
+	)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []Dependency{
+		{Type: Input, Path: abs(inputFileSwagger2Refs)},
+		{Type: Template, Path: abs(inputTmplFileOpenAPI)},
+		{Type: Import, Path: abs("./testdata/inputs/refs/components/swagger2_parameters.yml")},
+		{Type: Import, Path: abs("./testdata/inputs/refs/components/pet.yml")},
+	}, gen.Dependencies())
+
+	gen, err = New(
+		&parser.Option{Type: Input, Value: inputFileJSONSchema},
+		&parser.Option{Type: Loader, Value: "jsonschema"},
+		&parser.Option{Type: Template, Value: inputTmplFileJSONSchema},
+	)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []Dependency{
+		{Type: Input, Path: abs(inputFileJSONSchema)},
+		{Type: Template, Path: abs(inputTmplFileJSONSchema)},
+		{Type: Import, Path: abs("./testdata/inputs/jsonschema/owner.json")},
+	}, gen.Dependencies())
+}
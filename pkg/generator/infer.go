@@ -0,0 +1,158 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// extensionLoaders maps input file extensions to the loader types inferred
+// from them
+var extensionLoaders = map[string]string{
+	".json":    "json",
+	".yml":     "yaml",
+	".yaml":    "yaml",
+	".toml":    "toml",
+	".csv":     "csv",
+	".env":     "dotenv",
+	".graphql": "graphql",
+	".gql":     "graphql",
+	".proto":   "protobuf",
+	".sql":     "sql",
+	".go":      "go",
+}
+
+// inferLoaderType returns the loader type of an input file from its
+// extension. JSON and YAML specifications declaring an openapi or swagger
+// version are loaded by the matching OpenAPI loader.
+func inferLoaderType(path string, data string) string {
+	loaderType := extensionLoaders[strings.ToLower(filepath.Ext(path))]
+	if loaderType != "json" && loaderType != "yaml" {
+		return loaderType
+	}
+
+	if specType := sniffSpecType(data); len(specType) != 0 {
+		return specType
+	}
+	return loaderType
+}
+
+// inferDirLoaderType returns the loader type of an input directory from
+// the files it contains. Directories containing Go files are Go packages.
+func inferDirLoaderType(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.ToLower(filepath.Ext(entry.Name())) == ".go" {
+			return "go"
+		}
+	}
+	return ""
+}
+
+// specKeys are the top-level keys declaring the version of OpenAPI and
+// Swagger specifications
+var specKeys = map[string]bool{
+	"openapi": true,
+	"swagger": true,
+}
+
+// sniffSpecType returns the OpenAPI loader type of a specification from its
+// top-level openapi or swagger version, if any. Only the top-level keys are
+// scanned, so large documents are not decoded.
+func sniffSpecType(data string) string {
+	var versions map[string]string
+	if strings.HasPrefix(strings.TrimSpace(data), "{") {
+		versions = jsonTopLevelValues(data, specKeys)
+	} else {
+		versions = yamlTopLevelValues(data, specKeys)
+	}
+
+	if version, ok := versions["openapi"]; ok {
+		if strings.HasPrefix(version, "3.1") {
+			return "openapi31"
+		}
+		return "openapi3"
+	}
+	if _, ok := versions["swagger"]; ok {
+		return "swagger2"
+	}
+	return ""
+}
+
+// jsonTopLevelValues returns the scalar values of the given top-level keys
+// of a JSON object, skipping all other values
+func jsonTopLevelValues(data string, keys map[string]bool) map[string]string {
+	values := map[string]string{}
+
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return values
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return values
+		}
+		key, _ := tok.(string)
+
+		// nested objects and arrays are skipped by their delimiters
+		depth := 0
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				return values
+			}
+			switch tok {
+			case json.Delim('{'), json.Delim('['):
+				depth++
+			case json.Delim('}'), json.Delim(']'):
+				depth--
+			default:
+				if depth == 0 && keys[key] {
+					values[key] = fmt.Sprint(tok)
+				}
+			}
+			if depth == 0 {
+				break
+			}
+		}
+	}
+	return values
+}
+
+// yamlTopLevelValues returns the scalar values of the given top-level keys
+// of a YAML mapping in block style, skipping all nested lines
+func yamlTopLevelValues(data string, keys map[string]bool) map[string]string {
+	values := map[string]string{}
+
+	for _, line := range strings.Split(data, "\n") {
+		// nested lines are indented, comments and document markers are not
+		// keys
+		if len(line) == 0 || line[0] == ' ' || line[0] == '\t' || line[0] == '#' || strings.HasPrefix(line, "---") {
+			continue
+		}
+
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		key := strings.Trim(strings.TrimSpace(line[:colon]), `"'`)
+		if !keys[key] {
+			continue
+		}
+
+		value := line[colon+1:]
+		if comment := strings.Index(value, " #"); comment >= 0 {
+			value = value[:comment]
+		}
+		values[key] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	return values
+}
@@ -0,0 +1,170 @@
+package generator
+
+import (
+	"strings"
+	"unicode"
+)
+
+// irregularPlurals maps singular words to plurals not following the rules
+// of pluralize
+var irregularPlurals = map[string]string{
+	"analysis":   "analyses",
+	"axis":       "axes",
+	"calf":       "calves",
+	"child":      "children",
+	"crisis":     "crises",
+	"criterion":  "criteria",
+	"diagnosis":  "diagnoses",
+	"echo":       "echoes",
+	"foot":       "feet",
+	"goose":      "geese",
+	"half":       "halves",
+	"hero":       "heroes",
+	"hypothesis": "hypotheses",
+	"knife":      "knives",
+	"leaf":       "leaves",
+	"life":       "lives",
+	"loaf":       "loaves",
+	"man":        "men",
+	"matrix":     "matrices",
+	"mouse":      "mice",
+	"ox":         "oxen",
+	"person":     "people",
+	"potato":     "potatoes",
+	"quiz":       "quizzes",
+	"shelf":      "shelves",
+	"synopsis":   "synopses",
+	"thesis":     "theses",
+	"thief":      "thieves",
+	"tomato":     "tomatoes",
+	"tooth":      "teeth",
+	"vertex":     "vertices",
+	"wife":       "wives",
+	"wolf":       "wolves",
+	"woman":      "women",
+}
+
+// irregularSingulars maps plurals to singular words not following the rules
+// of singularize
+var irregularSingulars = func() map[string]string {
+	singulars := map[string]string{
+		"caches":   "cache",
+		"cookies":  "cookie",
+		"excuses":  "excuse",
+		"movies":   "movie",
+		"niches":   "niche",
+		"pies":     "pie",
+		"rookies":  "rookie",
+		"selfies":  "selfie",
+		"ties":     "tie",
+		"zombies":  "zombie",
+		"calories": "calorie",
+	}
+	for singular, plural := range irregularPlurals {
+		singulars[plural] = singular
+	}
+	return singulars
+}()
+
+// uncountables are the words with the same singular and plural
+var uncountables = wordSet(`auth deer equipment feedback fish information
+	info metadata money news rice series sheep software species data`)
+
+// pluralize returns the plural of an English word, keeping its case
+func pluralize(word string) string {
+	return inflect(word, func(lower string) string {
+		if uncountables[lower] {
+			return lower
+		}
+		if plural, ok := irregularPlurals[lower]; ok {
+			return plural
+		}
+		for _, suffix := range []string{"s", "x", "z", "ch", "sh"} {
+			if strings.HasSuffix(lower, suffix) {
+				return lower + "es"
+			}
+		}
+		if len(lower) > 1 && strings.HasSuffix(lower, "y") && !isVowel(lower[len(lower)-2]) {
+			return lower[:len(lower)-1] + "ies"
+		}
+		return lower + "s"
+	})
+}
+
+// singularize returns the singular of an English word, keeping its case
+func singularize(word string) string {
+	return inflect(word, func(lower string) string {
+		if uncountables[lower] {
+			return lower
+		}
+		if singular, ok := irregularSingulars[lower]; ok {
+			return singular
+		}
+		if _, ok := irregularPlurals[lower]; ok {
+			return lower
+		}
+		switch {
+		case strings.HasSuffix(lower, "ies") && len(lower) > 4:
+			return lower[:len(lower)-3] + "y"
+		case strings.HasSuffix(lower, "zzes"):
+			return lower[:len(lower)-3]
+		case strings.HasSuffix(lower, "sses"),
+			strings.HasSuffix(lower, "xes"),
+			strings.HasSuffix(lower, "ches"),
+			strings.HasSuffix(lower, "shes"):
+			return lower[:len(lower)-2]
+		case strings.HasSuffix(lower, "uses") && len(lower) > 5 && !isVowel(lower[len(lower)-5]):
+			return lower[:len(lower)-2]
+		case strings.HasSuffix(lower, "ss"),
+			strings.HasSuffix(lower, "us"),
+			strings.HasSuffix(lower, "is"):
+			return lower
+		case strings.HasSuffix(lower, "s") && len(lower) > 1:
+			return lower[:len(lower)-1]
+		}
+		return lower
+	})
+}
+
+// inflect applies an inflection to the lower case form of the last word of
+// a name, restoring the case of the word afterwards. Initialisms stay in
+// upper case with a lower case plural s, e.g. userIDs for userID.
+func inflect(name string, fn func(lower string) string) string {
+	parts := words(name)
+	if len(parts) == 0 {
+		return name
+	}
+	word := parts[len(parts)-1]
+	start := strings.LastIndex(name, word)
+	prefix, suffix := name[:start], name[start+len(word):]
+
+	inflected := fn(strings.ToLower(word))
+	switch {
+	case isUpperInitialism(word):
+		if upper, ok := initialism(inflected); ok {
+			inflected = upper
+		} else {
+			inflected = strings.ToUpper(inflected)
+		}
+	case word == strings.ToUpper(word) && len(word) > 1:
+		inflected = strings.ToUpper(inflected)
+	case unicode.IsUpper([]rune(word)[0]):
+		inflected = capitalize(inflected)
+	}
+	return prefix + inflected + suffix
+}
+
+// isUpperInitialism reports whether a word is an initialism written in upper
+// case, with or without a lower case plural s
+func isUpperInitialism(word string) bool {
+	upper := strings.TrimSuffix(word, "s")
+	if upper != strings.ToUpper(upper) {
+		return false
+	}
+	_, ok := initialism(word)
+	return ok
+}
+
+func isVowel(c byte) bool {
+	return strings.IndexByte("aeiou", c) >= 0
+}
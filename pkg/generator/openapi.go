@@ -0,0 +1,309 @@
+package generator
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// OpenAPIOperation is an operation of an OpenAPI document along with the
+// path and method it is defined for
+type OpenAPIOperation struct {
+	*openapi3.Operation
+	// Path is the path of the operation, e.g. /pets/{id}
+	Path string
+	// Method is the upper case HTTP method of the operation
+	Method string
+	// PathItem is the path item holding the operation
+	PathItem *openapi3.PathItem
+	// Parameters are the parameters of the path item merged with those of
+	// the operation, which override path item parameters of the same name
+	// and location
+	Parameters openapi3.Parameters
+}
+
+// OpenAPIRequestBody is the request body of an operation
+type OpenAPIRequestBody struct {
+	*openapi3.RequestBody
+	// Name is the name of the referenced request body, if any
+	Name string
+	// ContentTypes are the sorted media types of the request body
+	ContentTypes []string
+	// ContentType is the preferred media type, JSON if available
+	ContentType string
+	// Schema is the schema of the preferred media type
+	Schema *openapi3.SchemaRef
+}
+
+// OpenAPIResponse is a response of an operation
+type OpenAPIResponse struct {
+	*openapi3.Response
+	// Status is the status of the response as written in the document, e.g.
+	// 200, 4XX or default
+	Status string
+	// StatusCode is the numeric status, 0 for ranges and the default
+	// response
+	StatusCode int
+	// Name is the name of the referenced response, if any
+	Name string
+	// ContentTypes are the sorted media types of the response
+	ContentTypes []string
+	// ContentType is the preferred media type, JSON if available
+	ContentType string
+	// Schema is the schema of the preferred media type
+	Schema *openapi3.SchemaRef
+}
+
+// methodOrder is the order of the operations of a path item in the OpenAPI
+// specification
+var methodOrder = []string{
+	http.MethodGet,
+	http.MethodPut,
+	http.MethodPost,
+	http.MethodDelete,
+	http.MethodOptions,
+	http.MethodHead,
+	http.MethodPatch,
+	http.MethodTrace,
+	http.MethodConnect,
+}
+
+// operations returns the operations of an OpenAPI document, sorted by path
+// and method
+func operations(doc *openapi3.T) []*OpenAPIOperation {
+	if doc == nil {
+		return nil
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	out := make([]*OpenAPIOperation, 0)
+	for _, path := range paths {
+		item := doc.Paths[path]
+		ops := item.Operations()
+		for _, method := range methodOrder {
+			op, ok := ops[method]
+			if !ok {
+				continue
+			}
+			out = append(out, &OpenAPIOperation{
+				Operation:  op,
+				Path:       path,
+				Method:     method,
+				PathItem:   item,
+				Parameters: mergeParameters(item.Parameters, op.Parameters),
+			})
+		}
+	}
+	return out
+}
+
+// mergeParameters returns the path item parameters not overridden by the
+// operation, followed by the operation parameters
+func mergeParameters(item, op openapi3.Parameters) openapi3.Parameters {
+	overridden := map[string]bool{}
+	for _, param := range op {
+		if param.Value != nil {
+			overridden[param.Value.In+"\x00"+param.Value.Name] = true
+		}
+	}
+
+	merged := make(openapi3.Parameters, 0, len(item)+len(op))
+	for _, param := range item {
+		if param.Value == nil || !overridden[param.Value.In+"\x00"+param.Value.Name] {
+			merged = append(merged, param)
+		}
+	}
+	return append(merged, op...)
+}
+
+// parametersIn returns the parameters of an operation in a location, one of
+// path, query, header or cookie
+func parametersIn(in string, op interface{}) (openapi3.Parameters, error) {
+	var params openapi3.Parameters
+	switch o := op.(type) {
+	case *OpenAPIOperation:
+		params = o.Parameters
+	case *openapi3.Operation:
+		params = o.Parameters
+	case openapi3.Parameters:
+		params = o
+	default:
+		return nil, fmt.Errorf("unsupported operation type %T", op)
+	}
+
+	out := make(openapi3.Parameters, 0, len(params))
+	for _, param := range params {
+		if param.Value != nil && param.Value.In == in {
+			out = append(out, param)
+		}
+	}
+	return out, nil
+}
+
+// operationOf returns the operation of the values templates pass to the
+// OpenAPI functions
+func operationOf(op interface{}) (*openapi3.Operation, error) {
+	switch o := op.(type) {
+	case *OpenAPIOperation:
+		return o.Operation, nil
+	case *openapi3.Operation:
+		return o, nil
+	}
+	return nil, fmt.Errorf("unsupported operation type %T", op)
+}
+
+// requestBody returns the request body of an operation, or nil if it has
+// none
+func requestBody(op interface{}) (*OpenAPIRequestBody, error) {
+	o, err := operationOf(op)
+	if err != nil {
+		return nil, err
+	}
+	if o == nil || o.RequestBody == nil || o.RequestBody.Value == nil {
+		return nil, nil
+	}
+
+	body := &OpenAPIRequestBody{
+		RequestBody:  o.RequestBody.Value,
+		Name:         refName(o.RequestBody.Ref),
+		ContentTypes: contentTypes(o.RequestBody.Value.Content),
+	}
+	body.ContentType, body.Schema = preferredContent(o.RequestBody.Value.Content)
+	return body, nil
+}
+
+// responsesByStatus returns the responses of an operation sorted by status,
+// ranges after the codes they contain and the default response last
+func responsesByStatus(op interface{}) ([]*OpenAPIResponse, error) {
+	o, err := operationOf(op)
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		return nil, nil
+	}
+
+	out := make([]*OpenAPIResponse, 0, len(o.Responses))
+	for status, ref := range o.Responses {
+		if ref == nil || ref.Value == nil {
+			continue
+		}
+		code, _ := strconv.Atoi(status)
+		res := &OpenAPIResponse{
+			Response:     ref.Value,
+			Status:       status,
+			StatusCode:   code,
+			Name:         refName(ref.Ref),
+			ContentTypes: contentTypes(ref.Value.Content),
+		}
+		res.ContentType, res.Schema = preferredContent(ref.Value.Content)
+		out = append(out, res)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return statusKey(out[i].Status) < statusKey(out[j].Status)
+	})
+	return out, nil
+}
+
+// statusKey orders statuses like 200, 201, 2XX, 404, 4XX and default
+func statusKey(status string) string {
+	status = strings.ToUpper(status)
+	if status == "DEFAULT" {
+		return "9"
+	}
+	return strings.Replace(status, "X", "~", -1)
+}
+
+// contentTypes returns the sorted media types of a content map
+func contentTypes(content openapi3.Content) []string {
+	types := make([]string, 0, len(content))
+	for contentType := range content {
+		types = append(types, contentType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// preferredContent returns the media type and schema of content, preferring
+// application/json over other JSON media types and those over the first
+// media type
+func preferredContent(content openapi3.Content) (string, *openapi3.SchemaRef) {
+	types := contentTypes(content)
+	if len(types) == 0 {
+		return "", nil
+	}
+
+	preferred := types[0]
+	for _, contentType := range types {
+		if contentType == "application/json" {
+			preferred = contentType
+			break
+		}
+		if strings.HasSuffix(contentType, "+json") && !strings.HasSuffix(preferred, "json") {
+			preferred = contentType
+		}
+	}
+
+	if media := content[preferred]; media != nil {
+		return preferred, media.Schema
+	}
+	return preferred, nil
+}
+
+// refNameOf returns the name of the component a reference, or a value
+// holding a reference such as *openapi3.SchemaRef, points to. It is empty
+// for values that are not references.
+func refNameOf(ref interface{}) (string, error) {
+	if s, ok := ref.(string); ok {
+		return refName(s), nil
+	}
+
+	value := reflect.ValueOf(ref)
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return "", nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.Struct {
+		if field := value.FieldByName("Ref"); field.IsValid() && field.Kind() == reflect.String {
+			if field.String() == "" {
+				return "", nil
+			}
+			return refName(field.String()), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported reference type %T", ref)
+}
+
+// schemaName returns the name of a schema, the name of the schema it
+// references or otherwise its title
+func schemaName(schema interface{}) (string, error) {
+	switch s := schema.(type) {
+	case *openapi3.SchemaRef:
+		if s == nil {
+			return "", nil
+		}
+		if s.Ref != "" {
+			return refName(s.Ref), nil
+		}
+		return schemaName(s.Value)
+	case *openapi3.Schema:
+		if s == nil {
+			return "", nil
+		}
+		return s.Title, nil
+	}
+	return "", fmt.Errorf("unsupported schema type %T", schema)
+}
@@ -0,0 +1,156 @@
+package generator
+
+import (
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// SchemaCache holds schemas loaded from input files so that all generators
+// sharing a cache load each input file only once, even when generating code
+// in parallel. Entries are keyed by the absolute path of the input file, the
+// loader type and options and the modification time of the file, so a file
+// is loaded again once it or any file it imports changes. A SchemaCache is
+// safe for concurrent use.
+type SchemaCache struct {
+	mu      sync.Mutex
+	entries map[schemaKey]*schemaEntry
+}
+
+// schemaKey identifies an input file loaded by a specific loader
+type schemaKey struct {
+	path       string
+	loaderType string
+}
+
+// schemaEntry is a schema loaded from a specific version of an input file
+type schemaEntry struct {
+	modTime time.Time
+	once    sync.Once
+	data    interface{}
+	err     error
+	// imports are the modification times of the files imported by the input
+	// file when it was loaded, guarded by the mutex of the cache
+	imports map[string]time.Time
+	// loaded is set once imports has been recorded, guarded by the mutex of
+	// the cache
+	loaded bool
+}
+
+// NewSchemaCache creates an empty schema cache
+func NewSchemaCache() *SchemaCache {
+	return &SchemaCache{
+		entries: map[schemaKey]*schemaEntry{},
+	}
+}
+
+// load returns the cached schema of the input file or calls fn to load it.
+// imports is called before fn and returns the files the input file imports,
+// whose modification times the entry is validated against from then on.
+// Concurrent calls for the same input file wait for a single call of fn.
+// Maps and slices of the schema are copied for each call, so templates
+// changing them, e.g. with set, do not change the schema of other
+// generators.
+func (c *SchemaCache) load(path, loaderType string, modTime time.Time, imports func() ([]string, error), fn func() (interface{}, error)) (interface{}, error) {
+	key := schemaKey{path: path, loaderType: loaderType}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok || !entry.modTime.Equal(modTime) || entry.stale() {
+		// entries of previous versions of the file are replaced
+		entry = &schemaEntry{modTime: modTime}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	entry.once.Do(func() {
+		// imported files are stat'ed before the input is loaded, so changes
+		// made while loading invalidate the entry
+		var modTimes map[string]time.Time
+		paths, err := imports()
+		if err == nil {
+			modTimes = statFiles(paths)
+		}
+
+		entry.data, entry.err = fn()
+
+		c.mu.Lock()
+		entry.imports = modTimes
+		entry.loaded = true
+		c.mu.Unlock()
+	})
+
+	if entry.err != nil || entry.data == nil {
+		return entry.data, entry.err
+	}
+	return copySchema(reflect.ValueOf(entry.data)).Interface(), nil
+}
+
+// copySchema returns a deep copy of the maps and slices of the value, the
+// other values are shared
+func copySchema(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), copySchema(iter.Value()))
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(copySchema(v.Index(i)))
+		}
+		return out
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		elem := copySchema(v.Elem())
+		out := reflect.New(v.Type()).Elem()
+		out.Set(elem)
+		return out
+	}
+	return v
+}
+
+// stale reports whether any file imported by the input file changed since
+// the entry was loaded. Entries whose imports could not be resolved are
+// always stale. Must be called with the mutex of the cache held.
+func (e *schemaEntry) stale() bool {
+	if !e.loaded {
+		return false
+	}
+	if e.imports == nil {
+		return true
+	}
+	for path, modTime := range e.imports {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// statFiles returns the modification times of the files, or nil if any of
+// them cannot be stat'ed
+func statFiles(paths []string) map[string]time.Time {
+	modTimes := make(map[string]time.Time, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil
+		}
+		modTimes[path] = info.ModTime()
+	}
+	return modTimes
+}
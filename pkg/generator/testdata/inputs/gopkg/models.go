@@ -0,0 +1,60 @@
+// Package models holds the domain models of the pet store.
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// Kind is the kind of a pet
+type Kind string
+
+const (
+	// Cat is a cat
+	Cat Kind = "cat"
+	Dog Kind = "dog"
+)
+
+// MaxPets is the maximum number of pets of an owner
+const MaxPets = 10
+
+// Pet is a pet owned by an owner
+type Pet struct {
+	// ID identifies the pet
+	ID   string `json:"id" db:"pet_id"`
+	Name string `json:"name"` // display name
+	Kind Kind   `json:"kind"`
+	Born *time.Time
+	Tags []string `json:"tags,omitempty"`
+	*Owner
+	secret string
+}
+
+// Owner owns pets
+type Owner struct {
+	Name string
+	Pets map[string]*Pet
+}
+
+// Describe returns a description of the pet
+func (p *Pet) Describe(verbose bool) string {
+	return p.Name
+}
+
+// Store persists pets
+type Store interface {
+	Closer
+	// Get returns the pet with the given id
+	Get(ctx context.Context, id string) (*Pet, error)
+	List(ctx context.Context, kinds ...Kind) ([]*Pet, error)
+}
+
+// Closer releases resources
+type Closer interface {
+	Close() error
+}
+
+// NewPet creates a pet
+func NewPet(name string, kind Kind) *Pet {
+	return &Pet{Name: name, Kind: kind}
+}
@@ -0,0 +1,52 @@
+-- schema of the pet store
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TYPE pet_kind AS ENUM ('cat', 'dog', 'it''s complicated');
+
+CREATE TABLE owners (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "Email" VARCHAR(255) NOT NULL UNIQUE,
+    name TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE TABLE public.pets (
+    id BIGSERIAL,
+    owner_id UUID REFERENCES owners ON DELETE CASCADE,
+    kind pet_kind NOT NULL DEFAULT 'cat'::pet_kind,
+    name text NOT NULL CHECK (length(name) > 0),
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    weight NUMERIC(5, 2),
+    CONSTRAINT pets_pkey PRIMARY KEY (id),
+    CONSTRAINT pets_owner_name_key UNIQUE (owner_id, name)
+);
+
+CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS pets_lower_name_idx ON pets (lower(name)) WHERE owner_id IS NOT NULL;
+CREATE INDEX pets_kind_idx ON public.pets USING btree (kind DESC);
+
+CREATE FUNCTION touch() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at = now(); -- semicolons in function bodies are ignored
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TABLE visits (
+    id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    pet_id BIGINT NOT NULL,
+    visited_at TIMESTAMP(3) WITHOUT TIME ZONE,
+    notes TEXT,
+    FOREIGN KEY (pet_id) REFERENCES pets (id) ON UPDATE NO ACTION ON DELETE SET NULL
+);
+
+ALTER TABLE visits
+    ADD COLUMN IF NOT EXISTS cost NUMERIC(10, 2) DEFAULT 0,
+    ALTER COLUMN visited_at SET NOT NULL,
+    DROP COLUMN notes,
+    RENAME COLUMN pet_id TO patient_id;
+
+ALTER TABLE owners RENAME COLUMN name TO full_name;
+ALTER TABLE owners ALTER COLUMN full_name TYPE VARCHAR(100);
+
+CREATE TABLE legacy (id INT);
+DROP TABLE IF EXISTS legacy;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS [Owner] (
+    Id INTEGER PRIMARY KEY,
+    `Email` TEXT NOT NULL COLLATE NOCASE UNIQUE ON CONFLICT REPLACE
+);
+
+CREATE TABLE Pet (
+    Id INTEGER PRIMARY KEY AUTOINCREMENT,
+    OwnerId INTEGER NOT NULL REFERENCES [Owner](Id) ON DELETE CASCADE,
+    Name,
+    Full TEXT GENERATED ALWAYS AS (Name || '!') VIRTUAL
+) WITHOUT ROWID;
+
+CREATE INDEX Pet_Name ON Pet (Name COLLATE NOCASE ASC);
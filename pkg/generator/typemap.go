@@ -0,0 +1,228 @@
+package generator
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/knitcodegen/knit/pkg/loader"
+)
+
+// typeSchema is the part of an OpenAPI or JSON schema the type mappers use
+type typeSchema struct {
+	// Name is the name of the referenced schema, if any
+	Name     string
+	Type     string
+	Format   string
+	Nullable bool
+	Enum     []interface{}
+	Items    *typeSchema
+	// Values is the schema of additional properties of objects, if any
+	Values *typeSchema
+}
+
+// newTypeSchema converts the OpenAPI and JSON schemas of the loaders
+func newTypeSchema(schema interface{}) (*typeSchema, error) {
+	switch s := schema.(type) {
+	case *openapi3.SchemaRef:
+		if s == nil {
+			return nil, nil
+		}
+		if s.Ref != "" {
+			return &typeSchema{Name: refName(s.Ref)}, nil
+		}
+		return newTypeSchema(s.Value)
+	case *openapi3.Schema:
+		if s == nil {
+			return nil, nil
+		}
+		out := &typeSchema{
+			Type:     s.Type,
+			Format:   s.Format,
+			Nullable: s.Nullable,
+			Enum:     s.Enum,
+		}
+		if out.Type == "" && len(s.Properties) != 0 {
+			out.Type = "object"
+		}
+		var err error
+		if out.Items, err = newTypeSchema(s.Items); err != nil {
+			return nil, err
+		}
+		if out.Values, err = newTypeSchema(s.AdditionalProperties); err != nil {
+			return nil, err
+		}
+		if out.Values == nil && s.AdditionalPropertiesAllowed != nil && *s.AdditionalPropertiesAllowed && len(s.Properties) == 0 {
+			out.Values = &typeSchema{}
+		}
+		return out, nil
+	case *loader.JSONSchemaProperty:
+		if s == nil {
+			return nil, nil
+		}
+		return newTypeSchema(s.JSONSchema)
+	case *loader.JSONSchema:
+		if s == nil {
+			return nil, nil
+		}
+		if s.Ref != nil {
+			if s.Ref.Name != "" {
+				return &typeSchema{Name: s.Ref.Name, Nullable: s.Nullable}, nil
+			}
+			return newTypeSchema(s.Ref)
+		}
+		out := &typeSchema{
+			Type:     s.Type,
+			Format:   s.Format,
+			Nullable: s.Nullable,
+			Enum:     s.Enum,
+		}
+		if len(s.Types) > 1 {
+			out.Type = ""
+		}
+		if out.Type == "" && len(s.Types) <= 1 && len(s.Properties) != 0 {
+			out.Type = "object"
+		}
+		var err error
+		if out.Items, err = newTypeSchema(s.Items); err != nil {
+			return nil, err
+		}
+		if out.Values, err = newTypeSchema(s.AdditionalProperties); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unsupported schema type %T", schema)
+}
+
+// refName returns the name of the schema a reference points to, the last
+// segment of its fragment or the file name without its extension
+func refName(ref string) string {
+	file, fragment := ref, ""
+	if i := strings.Index(ref, "#"); i >= 0 {
+		file, fragment = ref[:i], ref[i+1:]
+	}
+	if name := path.Base(fragment); fragment != "" && name != "/" {
+		return name
+	}
+	name := path.Base(file)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// goType returns the Go type of an OpenAPI schema or JSON schema.
+// Referenced schemas are named after their Go name and nullable values are
+// pointers, except for slices, maps and interfaces.
+func goType(schema interface{}) (string, error) {
+	s, err := newTypeSchema(schema)
+	if err != nil {
+		return "", err
+	}
+	return goTypeOf(s), nil
+}
+
+func goTypeOf(s *typeSchema) string {
+	if s == nil {
+		return "interface{}"
+	}
+	if s.Name != "" {
+		return goPointer(s, goName(s.Name))
+	}
+
+	switch s.Type {
+	case "integer":
+		switch s.Format {
+		case "int32":
+			return goPointer(s, "int32")
+		case "int64":
+			return goPointer(s, "int64")
+		}
+		return goPointer(s, "int")
+	case "number":
+		if s.Format == "float" {
+			return goPointer(s, "float32")
+		}
+		return goPointer(s, "float64")
+	case "string":
+		switch s.Format {
+		case "date-time":
+			return goPointer(s, "time.Time")
+		case "byte", "binary":
+			return "[]byte"
+		}
+		return goPointer(s, "string")
+	case "boolean":
+		return goPointer(s, "bool")
+	case "array":
+		return "[]" + goTypeOf(s.Items)
+	case "object":
+		return "map[string]" + goTypeOf(s.Values)
+	}
+	return "interface{}"
+}
+
+func goPointer(s *typeSchema, typ string) string {
+	if s.Nullable {
+		return "*" + typ
+	}
+	return typ
+}
+
+// tsType returns the TypeScript type of an OpenAPI schema or JSON schema.
+// Referenced schemas are named after their TypeScript type name, string
+// enums are unions of literals and nullable values are unions with null.
+func tsType(schema interface{}) (string, error) {
+	s, err := newTypeSchema(schema)
+	if err != nil {
+		return "", err
+	}
+	return tsTypeOf(s), nil
+}
+
+func tsTypeOf(s *typeSchema) string {
+	if s == nil {
+		return "unknown"
+	}
+
+	typ := "unknown"
+	switch {
+	case s.Name != "":
+		typ = tsTypeName(s.Name)
+	case s.Type == "integer" || s.Type == "number":
+		typ = "number"
+	case s.Type == "string":
+		typ = "string"
+		if literals := tsLiterals(s.Enum); len(literals) != 0 {
+			typ = strings.Join(literals, " | ")
+		}
+	case s.Type == "boolean":
+		typ = "boolean"
+	case s.Type == "array":
+		items := tsTypeOf(s.Items)
+		if strings.Contains(items, " ") {
+			items = "(" + items + ")"
+		}
+		typ = items + "[]"
+	case s.Type == "object":
+		typ = "Record<string, " + tsTypeOf(s.Values) + ">"
+	}
+
+	if s.Nullable && typ != "unknown" {
+		return typ + " | null"
+	}
+	return typ
+}
+
+// tsLiterals returns the string literals of an enum, or nothing if any value
+// is not a string
+func tsLiterals(enum []interface{}) []string {
+	literals := make([]string, 0, len(enum))
+	for _, value := range enum {
+		s, ok := value.(string)
+		if !ok {
+			return nil
+		}
+		literals = append(literals, fmt.Sprintf("%q", s))
+	}
+	return literals
+}
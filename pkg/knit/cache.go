@@ -0,0 +1,72 @@
+package knit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// cache stores generated code blocks on disk, keyed by the fingerprint of
+// the generator that produced them
+type cache struct {
+	dir     string
+	version string
+}
+
+// key returns the cache key of a generator fingerprint. The knit version is
+// part of the key so upgrading knit invalidates all cached code blocks.
+func (c *cache) key(fingerprint string) string {
+	sum := sha256.Sum256([]byte(c.version + "\x00" + fingerprint))
+	return hex.EncodeToString(sum[:])
+}
+
+// path returns the location of a cache entry. Entries are spread across
+// sub directories to keep directory sizes small.
+func (c *cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// get returns the cached code block for the key, if any
+func (c *cache) get(key string) (string, bool) {
+	byt, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return "", false
+	}
+	return string(byt), true
+}
+
+// put stores a code block. The entry is written to a temporary file first so
+// concurrent readers never observe partially written entries.
+func (c *cache) put(key, codegen string) error {
+	path := c.path(key)
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return errors.Wrap(err, "failed to create cache directory")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create cache entry")
+	}
+
+	_, err = tmp.WriteString(codegen)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "failed to write cache entry")
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errors.Wrap(err, "failed to write cache entry")
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package knit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigFile is the name of the project level knit configuration file
+const ConfigFile = "knit.yaml"
+
+// DefaultCacheDir is the directory generated code blocks are cached in
+var DefaultCacheDir = filepath.Join(".knit", "cache")
+
+// ErrConfigNotFound is returned when no configuration file could be found
+var ErrConfigNotFound = errors.New("knit configuration file not found")
+
+// DefaultConfig returns the configuration used when a setting is neither
+// defined in a configuration file nor on the command line
+func DefaultConfig() *Config {
+	return &Config{
+		Format:   true,
+		Parallel: true,
+		CacheDir: DefaultCacheDir,
+		Debounce: 100 * time.Millisecond,
+	}
+}
+
+// FindConfig searches the given directory and all of its parents for a
+// knit configuration file. Returns ErrConfigNotFound if none exists.
+func FindConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to resolve absolute path to directory")
+	}
+
+	for {
+		path := filepath.Join(dir, ConfigFile)
+
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() {
+			return path, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrConfigNotFound
+		}
+		dir = parent
+	}
+}
+
+// LoadConfig reads the configuration file at the given path on top of the
+// default configuration. Relative paths and patterns defined in the file are
+// resolved against the directory containing it.
+func LoadConfig(path string) (*Config, error) {
+	byt, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read config file")
+	}
+
+	cfg := DefaultConfig()
+	err = yaml.UnmarshalStrict(byt, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal config file")
+	}
+
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve absolute path to config file")
+	}
+	dir := filepath.Dir(path)
+
+	cfg.Root = dir
+
+	cfg.Files = resolvePaths(dir, cfg.Files)
+	cfg.Include = resolvePatterns(dir, cfg.Include)
+	cfg.Exclude = resolvePatterns(dir, cfg.Exclude)
+	cfg.Templates = resolvePaths(dir, cfg.Templates)
+	cfg.Partials = resolvePaths(dir, cfg.Partials)
+	cfg.ImportPaths = resolvePaths(dir, cfg.ImportPaths)
+	cfg.CacheDir = resolvePaths(dir, []string{cfg.CacheDir})[0]
+
+	return cfg, nil
+}
+
+// resolvePaths joins all relative paths with the given directory
+func resolvePaths(dir string, paths []string) []string {
+	resolved := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		resolved = append(resolved, path)
+	}
+	return resolved
+}
+
+// resolvePatterns joins all relative patterns containing a path separator
+// with the given directory. Patterns without a separator match file names
+// in any directory and are left untouched.
+func resolvePatterns(dir string, patterns []string) []string {
+	resolved := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if strings.ContainsRune(filepath.ToSlash(pattern), '/') && !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		resolved = append(resolved, pattern)
+	}
+	return resolved
+}
@@ -0,0 +1,115 @@
+package knit
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/knitcodegen/knit/pkg/parser"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// unifiedDiff returns a unified diff between the original and generated text
+// of a file. Every hunk header is suffixed with the begin annotation of the
+// knit block the change belongs to and its line number, similar to the
+// function context shown by git. An empty string is returned when the texts
+// are equal.
+func unifiedDiff(file, original, generated string) string {
+	a := splitLines(original)
+	b := splitLines(generated)
+
+	groups := difflib.NewMatcher(a, b).GetGroupedOpCodes(diffContext)
+	if len(groups) == 0 {
+		return ""
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "--- %s\n", file)
+	fmt.Fprintf(sb, "+++ %s\n", file)
+
+	for _, group := range groups {
+		first, last := group[0], group[len(group)-1]
+
+		fmt.Fprintf(sb, "@@ -%s +%s @@", formatRange(first.I1, last.I2), formatRange(first.J1, last.J2))
+		if annotation := blockAnnotation(a, changeStart(group)); len(annotation) != 0 {
+			fmt.Fprintf(sb, " %s", annotation)
+		}
+		sb.WriteString("\n")
+
+		for _, op := range group {
+			if op.Tag == 'e' {
+				writeLines(sb, " ", a[op.I1:op.I2])
+				continue
+			}
+			if op.Tag == 'r' || op.Tag == 'd' {
+				writeLines(sb, "-", a[op.I1:op.I2])
+			}
+			if op.Tag == 'r' || op.Tag == 'i' {
+				writeLines(sb, "+", b[op.J1:op.J2])
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// changeStart returns the index of the first changed line in a hunk
+func changeStart(group []difflib.OpCode) int {
+	for _, op := range group {
+		if op.Tag != 'e' {
+			return op.I1
+		}
+	}
+	return group[0].I1
+}
+
+// blockAnnotation searches backwards from the given line for the nearest
+// begin annotation and returns it with surrounding whitespace removed,
+// followed by its line number so blocks can be told apart.
+func blockAnnotation(lines []string, from int) string {
+	if from >= len(lines) {
+		from = len(lines) - 1
+	}
+
+	for i := from; i >= 0; i-- {
+		if strings.Contains(lines[i], parser.ANNOTATION_BEG) {
+			return fmt.Sprintf("%s (line %d)", strings.TrimSpace(lines[i]), i+1)
+		}
+	}
+
+	return ""
+}
+
+// splitLines splits text into lines while keeping their line endings
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func writeLines(sb *strings.Builder, prefix string, lines []string) {
+	for _, line := range lines {
+		sb.WriteString(prefix)
+		sb.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// formatRange converts a line range to the unified diff format
+func formatRange(start, stop int) string {
+	beginning := start + 1
+	length := stop - start
+	if length == 1 {
+		return fmt.Sprintf("%d", beginning)
+	}
+	if length == 0 {
+		beginning--
+	}
+	return fmt.Sprintf("%d,%d", beginning, length)
+}
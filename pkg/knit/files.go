@@ -0,0 +1,197 @@
+package knit
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/knitcodegen/knit/pkg/parser"
+	"github.com/pkg/errors"
+)
+
+// ResolveFiles expands the given file patterns, or the configured default
+// file patterns if none are given, into a sorted list of files.
+//
+// Plain file paths are returned as they are. Directories and glob patterns,
+// including `**` to match any number of directories, are walked and only
+// files containing a knit begin annotation are returned. Files ignored by
+// git or not passing the configured include and exclude patterns are
+// skipped while walking.
+func ResolveFiles(cfg *Config, patterns []string) ([]string, error) {
+	if len(patterns) == 0 {
+		patterns = cfg.Files
+	}
+
+	w := &walker{
+		cfg:     cfg,
+		ignore:  &gitignore{loaded: map[string]bool{}},
+		seen:    map[string]bool{},
+		matched: make([]string, 0),
+	}
+
+	for _, pattern := range patterns {
+		var err error
+		if hasMeta(pattern) {
+			err = w.glob(pattern)
+		} else if info, statErr := os.Stat(pattern); statErr == nil && info.IsDir() {
+			err = w.walk(pattern, nil)
+		} else {
+			// keep plain file paths, even if they do not exist, so
+			// processing them reports an error instead of silently
+			// skipping them
+			w.add(filepath.Clean(pattern))
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to expand file pattern %s", pattern)
+		}
+	}
+
+	sort.Strings(w.matched)
+	return w.matched, nil
+}
+
+// walker collects annotated files while walking directories
+type walker struct {
+	cfg     *Config
+	ignore  *gitignore
+	seen    map[string]bool
+	matched []string
+}
+
+func (w *walker) add(file string) {
+	if w.seen[file] {
+		return
+	}
+	w.seen[file] = true
+	w.matched = append(w.matched, file)
+}
+
+// glob walks the static base directory of the pattern and collects every
+// file matching it
+func (w *walker) glob(pattern string) error {
+	pattern = path.Clean(filepath.ToSlash(pattern))
+	if !doublestar.ValidatePattern(pattern) {
+		return doublestar.ErrBadPattern
+	}
+
+	base, _ := doublestar.SplitPattern(pattern)
+
+	return w.walk(filepath.FromSlash(base), func(file string) bool {
+		ok, _ := doublestar.Match(pattern, filepath.ToSlash(file))
+		return ok
+	})
+}
+
+// walk collects all annotated files in the given directory, skipping
+// everything excluded by git or the knit configuration. If a match function
+// is given only files it accepts are collected.
+func (w *walker) walk(root string, match func(file string) bool) error {
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil
+	}
+
+	err := w.ignore.loadParents(root)
+	if err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if file != root && (d.Name() == ".git" || w.ignore.ignored(file, true) || matchAny(w.cfg.Exclude, file)) {
+				return filepath.SkipDir
+			}
+			return w.ignore.load(file)
+		}
+
+		if !d.Type().IsRegular() || w.ignore.ignored(file, false) || !w.cfg.Match(file) {
+			return nil
+		}
+
+		if match != nil && !match(file) {
+			return nil
+		}
+
+		annotated, err := hasAnnotation(file)
+		if err != nil {
+			return err
+		}
+
+		if annotated {
+			w.add(file)
+		}
+
+		return nil
+	})
+}
+
+// hasAnnotation reports whether the file contains a knit begin annotation
+func hasAnnotation(file string) (bool, error) {
+	byt, err := os.ReadFile(file)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read file")
+	}
+
+	return bytes.Contains(byt, []byte(parser.ANNOTATION_BEG)), nil
+}
+
+// hasMeta reports whether the pattern contains any glob syntax
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[{\`)
+}
+
+// Match reports whether the file passes the configured include and exclude
+// patterns. A file must match at least one include pattern, if any are
+// configured, and none of the exclude patterns.
+func (cfg *Config) Match(file string) bool {
+	if len(cfg.Include) != 0 && !matchAny(cfg.Include, file) {
+		return false
+	}
+
+	return !matchAny(cfg.Exclude, file)
+}
+
+// matchAny reports whether the file matches any of the patterns. Patterns
+// without a path separator are matched against the file name only, relative
+// patterns are matched against the path relative to the working directory.
+func matchAny(patterns []string, file string) bool {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		abs = file
+	}
+
+	rel := filepath.Clean(file)
+	if wd, err := os.Getwd(); err == nil {
+		if r, err := filepath.Rel(wd, abs); err == nil {
+			rel = r
+		}
+	}
+
+	for _, pattern := range patterns {
+		pattern = filepath.ToSlash(pattern)
+
+		target := rel
+		if filepath.IsAbs(filepath.FromSlash(pattern)) {
+			target = abs
+		} else if !strings.ContainsRune(pattern, '/') {
+			target = filepath.Base(file)
+		} else {
+			pattern = path.Clean(pattern)
+		}
+
+		if ok, _ := doublestar.Match(pattern, filepath.ToSlash(target)); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,173 @@
+package knit
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/pkg/errors"
+)
+
+// gitignoreFile is the name of the files git reads ignore rules from
+const gitignoreFile = ".gitignore"
+
+// ignoreRule is a single pattern read from a .gitignore file
+type ignoreRule struct {
+	// dir is the absolute path of the directory containing the .gitignore
+	dir string
+	// pattern is the pattern relative to dir
+	pattern string
+	// negate re-includes paths excluded by a previous rule
+	negate bool
+	// dirOnly rules only match directories
+	dirOnly bool
+	// anchored rules are matched against the path relative to dir instead
+	// of the file name
+	anchored bool
+}
+
+// gitignore matches paths against the rules of all .gitignore files loaded
+// while walking a directory tree
+type gitignore struct {
+	rules  []ignoreRule
+	loaded map[string]bool
+}
+
+// loadParents loads the .gitignore files of all parent directories of the
+// given directory up to the root of the git repository it belongs to
+func (g *gitignore) loadParents(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return errors.Wrap(err, "failed to resolve absolute path to directory")
+	}
+
+	parents := make([]string, 0)
+	for {
+		parents = append(parents, abs)
+
+		if _, err := os.Stat(filepath.Join(abs, ".git")); err == nil {
+			break
+		}
+
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			// not inside a git repository
+			return nil
+		}
+		abs = parent
+	}
+
+	// rules of the outermost directories are loaded first so rules closer
+	// to a file take precedence
+	for i := len(parents) - 1; i > 0; i-- {
+		err := g.load(parents[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// load reads the rules of the .gitignore file in the given directory, if it
+// exists and has not been loaded yet
+func (g *gitignore) load(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return errors.Wrap(err, "failed to resolve absolute path to directory")
+	}
+
+	if g.loaded[abs] {
+		return nil
+	}
+	g.loaded[abs] = true
+
+	file, err := os.Open(filepath.Join(abs, gitignoreFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to open .gitignore")
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(abs, scanner.Text()); ok {
+			g.rules = append(g.rules, rule)
+		}
+	}
+
+	return errors.Wrap(scanner.Err(), "failed to read .gitignore")
+}
+
+// parseIgnoreRule parses a single line of a .gitignore file. Returns false
+// for blank lines and comments.
+func parseIgnoreRule(dir, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if len(line) == 0 || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	rule := ignoreRule{dir: dir}
+
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+
+	if len(line) == 0 {
+		return ignoreRule{}, false
+	}
+
+	rule.pattern = line
+	return rule, true
+}
+
+// ignored reports whether the path is ignored by the loaded rules. As in git,
+// the last matching rule decides.
+func (g *gitignore) ignored(file string, isDir bool) bool {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+
+	ignored := false
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		rel, err := filepath.Rel(rule.dir, abs)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		target := rel
+		if !rule.anchored {
+			target = path.Base(rel)
+		}
+
+		if ok, _ := doublestar.Match(rule.pattern, target); ok {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
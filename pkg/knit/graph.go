@@ -0,0 +1,203 @@
+package knit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// NodeType is the type of a node in the dependency graph
+type NodeType = string
+
+const (
+	// FileNode is an annotated file knit generates code into
+	FileNode NodeType = "file"
+	// InputNode is an input file read by a generator
+	InputNode NodeType = "input"
+	// TemplateNode is a template file read by a generator
+	TemplateNode NodeType = "template"
+	// ImportNode is a file imported by an input file
+	ImportNode NodeType = "import"
+	// OperationsNode is a GraphQL operations file read by a generator
+	OperationsNode NodeType = "operations"
+	// IncludeNode is a template file parsed along with a template
+	IncludeNode NodeType = "include"
+)
+
+// nodePrecedence ranks the types of a file that has several roles, the node
+// gets the type with the lowest rank regardless of the order of edges
+var nodePrecedence = map[NodeType]int{
+	FileNode:       0,
+	InputNode:      1,
+	TemplateNode:   2,
+	IncludeNode:    3,
+	OperationsNode: 4,
+	ImportNode:     5,
+}
+
+// Graph is the dependency graph from annotated files to the input, template
+// and imported files their generators read
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// GraphNode is a file in the dependency graph
+type GraphNode struct {
+	// ID is the path of the file, relative to the working directory if
+	// possible
+	ID string `json:"id"`
+	// Type is the type of the file. A file with several roles, such as a
+	// file used both as an input and as a template, has the type that
+	// comes first in the order file, input, template, include, operations
+	// and import.
+	Type NodeType `json:"type"`
+}
+
+// GraphEdge is a dependency of an annotated file on an input or template
+type GraphEdge struct {
+	// From is the ID of the annotated file
+	From string `json:"from"`
+	// To is the ID of the input or template file
+	To string `json:"to"`
+	// Type is the type of option the dependency was configured with
+	Type string `json:"type"`
+}
+
+// Graph builds the dependency graph of the given annotated files
+func (k *knit) Graph(files []string) (*Graph, error) {
+	nodes := map[string]NodeType{}
+	edges := map[GraphEdge]bool{}
+
+	for _, file := range files {
+		from := graphPath(file)
+		setNodeType(nodes, from, FileNode)
+
+		deps, err := k.Dependencies(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve dependencies of %s", file)
+		}
+
+		for _, dep := range deps {
+			to := graphPath(dep.Path)
+			setNodeType(nodes, to, dep.Type)
+			edges[GraphEdge{From: from, To: to, Type: dep.Type}] = true
+		}
+	}
+
+	g := &Graph{
+		Nodes: make([]GraphNode, 0, len(nodes)),
+		Edges: make([]GraphEdge, 0, len(edges)),
+	}
+
+	for id, typ := range nodes {
+		g.Nodes = append(g.Nodes, GraphNode{ID: id, Type: typ})
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		return g.Nodes[i].ID < g.Nodes[j].ID
+	})
+
+	for edge := range edges {
+		g.Edges = append(g.Edges, edge)
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Type < b.Type
+	})
+
+	return g, nil
+}
+
+// setNodeType sets the type of a node unless it already has a type of
+// higher precedence
+func setNodeType(nodes map[string]NodeType, id string, typ NodeType) {
+	current, ok := nodes[id]
+	if !ok || nodePrecedence[typ] < nodePrecedence[current] {
+		nodes[id] = typ
+	}
+}
+
+// Dependents returns the annotated files that are regenerated when the file
+// at the given path changes
+func (g *Graph) Dependents(path string) []string {
+	id := graphPath(path)
+
+	seen := map[string]bool{}
+	dependents := make([]string, 0)
+	for _, edge := range g.Edges {
+		if edge.To == id && !seen[edge.From] {
+			seen[edge.From] = true
+			dependents = append(dependents, edge.From)
+		}
+	}
+
+	return dependents
+}
+
+// JSON encodes the graph as indented JSON
+func (g *Graph) JSON() ([]byte, error) {
+	byt, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal graph")
+	}
+	return append(byt, '\n'), nil
+}
+
+// DOT encodes the graph in the Graphviz DOT language
+func (g *Graph) DOT() string {
+	shapes := map[NodeType]string{
+		FileNode:       "box",
+		InputNode:      "ellipse",
+		TemplateNode:   "note",
+		ImportNode:     "ellipse",
+		OperationsNode: "ellipse",
+		IncludeNode:    "note",
+	}
+
+	sb := &strings.Builder{}
+	sb.WriteString("digraph knit {\n")
+	sb.WriteString("  rankdir=LR;\n")
+
+	for _, node := range g.Nodes {
+		fmt.Fprintf(sb, "  %q [shape=%s];\n", node.ID, shapes[node.Type])
+	}
+
+	for _, edge := range g.Edges {
+		fmt.Fprintf(sb, "  %q -> %q [label=%q];\n", edge.From, edge.To, edge.Type)
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// graphPath returns the path relative to the working directory, or the
+// cleaned path if it is outside of it
+func graphPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.ToSlash(filepath.Clean(path))
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return filepath.ToSlash(abs)
+	}
+
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(abs)
+	}
+
+	return filepath.ToSlash(rel)
+}
@@ -2,14 +2,19 @@ package knit
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"go/format"
+	"log"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/knitcodegen/knit/pkg/generator"
+	"github.com/knitcodegen/knit/pkg/loader"
 	"github.com/knitcodegen/knit/pkg/parser"
 	"github.com/pkg/errors"
 )
@@ -21,18 +26,79 @@ type Config struct {
 	Verbose bool `yaml:"verbose"`
 	// Parallel tells knit to process input files in parallel
 	Parallel bool `yaml:"parallel"`
+	// DryRun tells knit to generate code without writing any changes to disk
+	DryRun bool `yaml:"-"`
+	// Diff tells knit to compute a unified diff of pending changes instead of
+	// writing them to disk
+	Diff bool `yaml:"-"`
+	// FailFast tells knit to stop processing files after the first failure.
+	// DryRun, Diff and FailFast are modes of a single invocation, so they
+	// cannot be set in the configuration file.
+	FailFast bool `yaml:"-"`
+	// Jobs limits the number of files processed in parallel. Defaults to
+	// GOMAXPROCS if not positive.
+	Jobs int `yaml:"jobs"`
+	// Ordered tells knit to report processed files in the order they were
+	// provided, even when processing them in parallel
+	Ordered bool `yaml:"ordered"`
+	// Cache tells knit to reuse previously generated code blocks whose
+	// options, input and template have not changed
+	Cache bool `yaml:"cache"`
+	// CacheDir is the directory generated code blocks are cached in,
+	// relative paths are resolved against Root
+	CacheDir string `yaml:"cacheDir"`
+	// Version identifies the knit build, cached code blocks generated by
+	// other builds are not reused
+	Version string `yaml:"-"`
+	// Debounce is how long knit waits for further changes before
+	// processing changed files in watch mode
+	Debounce time.Duration `yaml:"debounce"`
+	// Files are the file patterns processed when no files are provided
+	Files []string `yaml:"files"`
+	// Include are patterns a file must match to be processed
+	Include []string `yaml:"include"`
+	// Exclude are patterns of files that are never processed
+	Exclude []string `yaml:"exclude"`
+	// Loaders maps custom loader names to registered loader types
+	Loaders map[string]string `yaml:"loaders"`
+	// Registry holds the loader types available to code blocks, including
+	// custom loaders registered by library users. Defaults to
+	// loader.DefaultRegistry if nil.
+	Registry *loader.Registry `yaml:"-"`
+	// Engines maps the names of custom template engines to their
+	// implementation, for library users
+	Engines map[string]generator.TemplateEngine `yaml:"-"`
+	// Templates are directories searched for template files that cannot be
+	// found relative to the working directory
+	Templates []string `yaml:"templates"`
+	// Partials are patterns of template files parsed along with every
+	// template, so all templates can use their define blocks
+	Partials []string `yaml:"partials"`
+	// ImportPaths are directories searched for files imported by inputs,
+	// such as .proto files
+	ImportPaths []string `yaml:"importPaths"`
+	// RestrictRefs tells knit to reject references from inputs to files
+	// outside of the project root
+	RestrictRefs bool `yaml:"restrictRefs"`
+	// Root is the project root, the directory containing the configuration
+	// file. Defaults to the working directory if empty.
+	Root string `yaml:"-"`
 }
 
 // ProcessResult represents a file that has been processed by knit
 type ProcessResult struct {
 	// The file that was processed
 	File string
-	// Was the file modified during processing
+	// Was the file modified during processing. In dry run mode this reports
+	// whether the file would have been modified.
 	Modified bool
 	// An error, if any, that occured during processing
 	Error error
 	// How long it took to process the file
 	Time time.Duration
+	// A unified diff of the pending changes, if knit is configured to
+	// compute diffs and the file would have been modified
+	Diff string
 }
 
 // OnFileProcessed is a callback function used to notify callers when knit is
@@ -43,18 +109,112 @@ type Knit interface {
 	ProcessText(text string) (string, error)
 	ProcessFile(filepath string) ProcessResult
 	ProcessFiles(filepaths []string, fn OnFileProcessed)
+	ProcessFilesContext(ctx context.Context, filepaths []string, fn OnFileProcessed)
+	Dependencies(filepath string) ([]generator.Dependency, error)
+	Graph(filepaths []string) (*Graph, error)
+	Watch(ctx context.Context, filepaths []string, fn OnFileProcessed) error
 }
 
 type knit struct {
 	cfg *Config
+	// schemas are shared by all generators so every input file is only
+	// loaded once per knit instance
+	schemas *generator.SchemaCache
 }
 
 func New(cfg *Config) Knit {
 	return &knit{
-		cfg: cfg,
+		cfg:     cfg,
+		schemas: generator.NewSchemaCache(),
 	}
 }
 
+// GeneratorConfig returns the generator configuration for the knit
+// configuration, without a schema cache
+func (cfg *Config) GeneratorConfig() *generator.Config {
+	return &generator.Config{
+		Loaders:       cfg.Loaders,
+		Registry:      cfg.Registry,
+		Engines:       cfg.Engines,
+		TemplatePaths: cfg.Templates,
+		Partials:      cfg.Partials,
+		ImportPaths:   cfg.ImportPaths,
+		RefRoot:       cfg.refRoot(),
+		Verbose:       cfg.Verbose,
+	}
+}
+
+// refRoot returns the directory files referenced by inputs are restricted
+// to, if references are restricted
+func (cfg *Config) refRoot() string {
+	if !cfg.RestrictRefs {
+		return ""
+	}
+	if len(cfg.Root) != 0 {
+		return cfg.Root
+	}
+	return "."
+}
+
+// generatorConfig returns the configuration shared by all generators
+// created by knit
+func (k *knit) generatorConfig() *generator.Config {
+	cfg := k.cfg.GeneratorConfig()
+	cfg.Schemas = k.schemas
+	return cfg
+}
+
+// cacheDir returns the directory code blocks are cached in, resolving
+// relative directories against the project root so all invocations within
+// the project share the cache
+func (k *knit) cacheDir() string {
+	dir := k.cfg.CacheDir
+	if len(dir) == 0 {
+		dir = DefaultCacheDir
+	}
+	if filepath.IsAbs(dir) || len(k.cfg.Root) == 0 {
+		return dir
+	}
+	return filepath.Join(k.cfg.Root, dir)
+}
+
+// generate runs the generator, reusing the cached code block of an identical
+// generator if knit is configured to use the cache
+func (k *knit) generate(gen generator.Generator) (string, error) {
+	if !k.cfg.Cache {
+		return gen.Generate()
+	}
+
+	fingerprint, err := gen.Fingerprint()
+	if err != nil {
+		return "", err
+	}
+
+	c := &cache{dir: k.cacheDir(), version: k.cfg.Version}
+	key := c.key(fingerprint)
+
+	if codegen, ok := c.get(key); ok {
+		return codegen, nil
+	}
+
+	codegen, err := gen.Generate()
+	if err != nil {
+		return "", err
+	}
+
+	// dry runs and diffs must not write to disk, and failing to cache a code
+	// block only slows down the next run
+	if k.cfg.DryRun || k.cfg.Diff {
+		return codegen, nil
+	}
+	err = c.put(key, codegen)
+	if err != nil && k.cfg.Verbose {
+		log.Printf("knit failed to cache generated code\n%+v", err)
+	}
+
+	return codegen, nil
+}
+
 // ProcessText parses knit options and executes all configured codegen templates
 func (k *knit) ProcessText(text string) (string, error) {
 	b := strings.Builder{}
@@ -81,12 +241,12 @@ func (k *knit) ProcessText(text string) (string, error) {
 			return "", errors.Wrap(err, "failed to parse knit options")
 		}
 
-		generator, err := generator.New(opts...)
+		generator, err := generator.NewWithConfig(k.generatorConfig(), opts...)
 		if err != nil {
 			return "", errors.Wrap(err, "failed to setup generator context")
 		}
 
-		codegen, err := generator.Generate()
+		codegen, err := k.generate(generator)
 		if err != nil {
 			return "", errors.Wrap(err, "failed to generate knit code block")
 		}
@@ -105,8 +265,40 @@ func (k *knit) ProcessText(text string) (string, error) {
 	return b.String(), nil
 }
 
+// Dependencies reads and parses knit options from file and returns the input
+// and template files read by all of its generators
+func (k *knit) Dependencies(filepath string) ([]generator.Dependency, error) {
+	file, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load file")
+	}
+
+	deps := make([]generator.Dependency, 0)
+	for _, block := range strings.SplitAfter(string(file), parser.ANNOTATION_END) {
+		if _, err := parser.BeginAnnotation(block); err != nil {
+			continue
+		}
+
+		opts, err := parser.Options(block)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse knit options")
+		}
+
+		gen, err := generator.NewWithConfig(k.generatorConfig(), opts...)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to setup generator context")
+		}
+
+		deps = append(deps, gen.Dependencies()...)
+	}
+
+	return deps, nil
+}
+
 // ProcessFile reads and parses knit options from file
-// then executes all configured codegen templates
+// then executes all configured codegen templates. When knit is configured
+// for a dry run or to compute diffs the file is left untouched and the result
+// only reports whether, and optionally how, it would have been modified.
 func (k *knit) ProcessFile(filepath string) ProcessResult {
 	startTime := time.Now()
 
@@ -144,6 +336,23 @@ func (k *knit) ProcessFile(filepath string) ProcessResult {
 
 	textSum := md5.New().Sum([]byte(text))
 	if !bytes.Equal(fileSum, textSum) {
+		if k.cfg.Diff {
+			return ProcessResult{
+				File:     filepath,
+				Time:     time.Since(startTime),
+				Modified: true,
+				Diff:     unifiedDiff(filepath, string(file), text),
+			}
+		}
+
+		if k.cfg.DryRun {
+			return ProcessResult{
+				File:     filepath,
+				Time:     time.Since(startTime),
+				Modified: true,
+			}
+		}
+
 		err = os.WriteFile(filepath, []byte(text), os.ModeExclusive)
 		if err != nil {
 			return ProcessResult{
@@ -166,32 +375,104 @@ func (k *knit) ProcessFile(filepath string) ProcessResult {
 	}
 }
 
-// ProcessFiles takes a slice of file paths and processes all of them. If
-// knit is configured to run in parallel, goroutines are spawned for each
-// file to handle the processing work. The OnFileProcessed function provided
-// will be called after each file has been successfully processed by knit.
+// ProcessFiles processes all files without a deadline or cancellation. See
+// ProcessFilesContext for details.
 func (k *knit) ProcessFiles(files []string, fn OnFileProcessed) {
-	var wg sync.WaitGroup
+	k.ProcessFilesContext(context.Background(), files, fn)
+}
 
-	for _, file := range files {
-		if k.cfg.Parallel {
-			wg.Add(1)
-			go func(file string) {
-				defer wg.Done()
-				res := k.ProcessFile(file)
+// ProcessFilesContext takes a slice of file paths and processes all of them.
+// If knit is configured to run in parallel, files are processed by a pool of
+// workers limited to the configured number of jobs. The OnFileProcessed
+// function provided will be called after each file has been processed by
+// knit, in the order of the given files if knit is configured to deliver
+// ordered results. Files that have not started processing when the context
+// is cancelled, or when a file fails and knit is configured to fail fast, are
+// skipped and not reported.
+func (k *knit) ProcessFilesContext(ctx context.Context, files []string, fn OnFileProcessed) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-				if fn != nil {
-					fn(res)
-				}
-			}(file)
-		} else {
-			res := k.ProcessFile(file)
+	jobs := 1
+	if k.cfg.Parallel {
+		jobs = k.cfg.Jobs
+		if jobs <= 0 {
+			jobs = runtime.GOMAXPROCS(0)
+		}
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = make([]*ProcessResult, len(files))
+		done    = make([]bool, len(files))
+		next    int
+	)
+
+	// report calls fn with the result, or with all results that are ready
+	// to be delivered in order. A nil result marks a file skipped after
+	// cancellation, which is not delivered but no longer holds back the
+	// results of the files after it.
+	report := func(i int, res *ProcessResult) {
+		if fn == nil {
+			return
+		}
+
+		if !k.cfg.Ordered {
+			if res != nil {
+				fn(*res)
+			}
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
 
-			if fn != nil {
-				fn(res)
+		results[i], done[i] = res, true
+		for next < len(results) && done[next] {
+			if results[next] != nil {
+				fn(*results[next])
+				results[next] = nil
 			}
+			next++
 		}
 	}
 
+	indexes := make(chan int)
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if ctx.Err() != nil {
+					report(i, nil)
+					continue
+				}
+
+				res := k.ProcessFile(files[i])
+				if res.Error != nil && k.cfg.FailFast {
+					cancel()
+				}
+				report(i, &res)
+			}
+		}()
+	}
+
+	func() {
+		defer close(indexes)
+		for i := range files {
+			// stop handing out files once processing has been cancelled
+			if ctx.Err() != nil {
+				return
+			}
+
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
 	wg.Wait()
 }
@@ -1,10 +1,17 @@
 package knit
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/bradleyjkemp/cupaloy"
+	"github.com/knitcodegen/knit/pkg/generator"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -25,6 +32,7 @@ func Test_Knit(t *testing.T) {
 	type want struct {
 		err        bool
 		errMessage string
+		modified   bool
 	}
 
 	cases := []struct {
@@ -72,6 +80,20 @@ func Test_Knit(t *testing.T) {
 			},
 			want: want{},
 		},
+		{
+			name: "does not write stale file in dry run mode",
+			input: input{
+				knit: &knit{
+					cfg: &Config{
+						DryRun: true,
+					},
+				},
+				file: "./testdata/stale",
+			},
+			want: want{
+				modified: true,
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -80,7 +102,456 @@ func Test_Knit(t *testing.T) {
 			if c.want.err {
 				assert.Errorf(t, res.Error, c.want.errMessage)
 			}
+			assert.Equal(t, c.want.modified, res.Modified)
 			cupaloy.SnapshotT(t, fromFile(t, c.input.file))
 		})
 	}
 }
+
+func Test_Diff(t *testing.T) {
+	cases := []struct {
+		name string
+		file string
+	}{
+		{
+			name: "diffs stale file",
+			file: "./testdata/stale",
+		},
+		{
+			name: "handles file without changes",
+			file: "./testdata/empty",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			k := &knit{
+				cfg: &Config{
+					Diff: true,
+				},
+			}
+
+			res := k.ProcessFile(c.file)
+			assert.NoError(t, res.Error)
+			cupaloy.SnapshotT(t, res.Diff)
+		})
+	}
+}
+
+func Test_Diff_BlockLines(t *testing.T) {
+	block := "// @+knit\n%s\n// @!knit\n"
+	filler := strings.Repeat("\n", 10)
+	original := fmt.Sprintf(block, "a") + filler + fmt.Sprintf(block, "b")
+	generated := fmt.Sprintf(block, "A") + filler + fmt.Sprintf(block, "B")
+
+	diff := unifiedDiff("file", original, generated)
+	assert.Contains(t, diff, "@@ // @+knit (line 1)\n")
+	assert.Contains(t, diff, "@@ // @+knit (line 14)\n")
+}
+
+func Test_LoadConfig(t *testing.T) {
+	path, err := FindConfig("./testdata/config/nested")
+	assert.NoError(t, err)
+
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+
+	dir, err := filepath.Abs("./testdata/config")
+	assert.NoError(t, err)
+
+	assert.Equal(t, &Config{
+		Format:       false,
+		Verbose:      true,
+		Parallel:     true,
+		Files:        []string{filepath.Join(dir, "src/*.go")},
+		Include:      []string{"*.go"},
+		Exclude:      []string{"*_test.go", filepath.Join(dir, "src/vendor/*")},
+		Loaders:      map[string]string{"spec": "openapi3"},
+		Templates:    []string{filepath.Join(dir, "templates")},
+		Partials:     []string{filepath.Join(dir, "templates/partials/**/*.tmpl")},
+		ImportPaths:  []string{filepath.Join(dir, "protos")},
+		RestrictRefs: true,
+		Root:         dir,
+		CacheDir:     filepath.Join(dir, ".knit", "cache"),
+		Debounce:     100 * time.Millisecond,
+	}, cfg)
+
+	assert.True(t, cfg.Match(filepath.Join(dir, "src/main.go")))
+	assert.False(t, cfg.Match(filepath.Join(dir, "src/main_test.go")))
+	assert.False(t, cfg.Match(filepath.Join(dir, "src/vendor/lib.go")))
+	assert.False(t, cfg.Match(filepath.Join(dir, "src/README.md")))
+}
+
+func Test_LoadConfig_InvocationModes(t *testing.T) {
+	for _, key := range []string{"dryRun", "diff", "failFast"} {
+		t.Run(key, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ConfigFile)
+			err := os.WriteFile(path, []byte(key+": true\n"), 0644)
+			assert.NoError(t, err)
+
+			_, err = LoadConfig(path)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func Test_ResolveFiles(t *testing.T) {
+	cfg := &Config{
+		Exclude: []string{"*_test.go"},
+	}
+
+	cases := []struct {
+		name     string
+		patterns []string
+		want     []string
+	}{
+		{
+			name:     "walks directory",
+			patterns: []string{"./testdata/walk"},
+			want: []string{
+				"testdata/walk/a.go",
+				"testdata/walk/sub/c.go",
+				"testdata/walk/sub/e.gen.go",
+			},
+		},
+		{
+			name:     "expands recursive glob",
+			patterns: []string{"./testdata/walk/**/*.gen.go"},
+			want: []string{
+				"testdata/walk/sub/e.gen.go",
+			},
+		},
+		{
+			name:     "keeps plain file paths",
+			patterns: []string{"./testdata/walk/b.go", "./testdata/does_not_exist"},
+			want: []string{
+				"testdata/does_not_exist",
+				"testdata/walk/b.go",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			want := make([]string, 0, len(c.want))
+			for _, file := range c.want {
+				want = append(want, filepath.FromSlash(file))
+			}
+
+			files, err := ResolveFiles(cfg, c.patterns)
+			assert.NoError(t, err)
+			assert.Equal(t, want, files)
+		})
+	}
+}
+
+func Test_ProcessFiles(t *testing.T) {
+	files := []string{"./testdata/no_options", "./testdata/empty"}
+
+	cases := []struct {
+		name string
+		cfg  *Config
+		want Summary
+	}{
+		{
+			name: "keeps going after failure",
+			cfg:  &Config{},
+			want: Summary{Unchanged: 1, Failed: []ProcessResult{{File: "./testdata/no_options"}}},
+		},
+		{
+			name: "stops after first failure",
+			cfg:  &Config{FailFast: true},
+			want: Summary{Failed: []ProcessResult{{File: "./testdata/no_options"}}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			summary := Summary{}
+			New(c.cfg).ProcessFiles(files, summary.Add)
+
+			assert.Equal(t, c.want.Unchanged, summary.Unchanged)
+			assert.Equal(t, c.want.Modified, summary.Modified)
+			assert.Equal(t, len(c.want.Failed), len(summary.Failed))
+			for i, res := range summary.Failed {
+				assert.Equal(t, c.want.Failed[i].File, res.File)
+				assert.Error(t, res.Error)
+			}
+		})
+	}
+}
+
+func Test_Dependencies(t *testing.T) {
+	abs := func(path string) string {
+		abs, err := filepath.Abs(path)
+		assert.NoError(t, err)
+		return abs
+	}
+
+	deps, err := New(&Config{}).Dependencies("./testdata/dependencies")
+	assert.NoError(t, err)
+	assert.Equal(t, []generator.Dependency{
+		{Type: generator.Input, Path: abs("./testdata/schema.yml")},
+		{Type: generator.Template, Path: abs("./testdata/template.tmpl")},
+		{Type: generator.Template, Path: abs("./testdata/template.tmpl")},
+	}, deps)
+}
+
+func Test_Graph(t *testing.T) {
+	graph, err := New(&Config{}).Graph([]string{"./testdata/dependencies", "./testdata/stale"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"testdata/dependencies"}, graph.Dependents("./testdata/schema.yml"))
+
+	t.Run("json", func(t *testing.T) {
+		byt, err := graph.JSON()
+		assert.NoError(t, err)
+		cupaloy.SnapshotT(t, string(byt))
+	})
+
+	t.Run("dot", func(t *testing.T) {
+		cupaloy.SnapshotT(t, graph.DOT())
+	})
+}
+
+func Test_Graph_Roles(t *testing.T) {
+	orders := [][]string{
+		{"./testdata/roles", "./testdata/dependencies"},
+		{"./testdata/dependencies", "./testdata/roles"},
+	}
+
+	for _, files := range orders {
+		graph, err := New(&Config{}).Graph(files)
+		assert.NoError(t, err)
+		assert.Contains(t, graph.Nodes, GraphNode{ID: "testdata/template.tmpl", Type: InputNode})
+		assert.Contains(t, graph.Nodes, GraphNode{ID: "testdata/roles", Type: FileNode})
+	}
+}
+
+func Test_Cache(t *testing.T) {
+	text := fromFile(t, "./testdata/stale")
+
+	k := New(&Config{
+		Cache:    true,
+		CacheDir: t.TempDir(),
+		Version:  "test",
+	})
+
+	generated, err := k.ProcessText(text)
+	assert.NoError(t, err)
+
+	// replace the cached code block to ensure it is reused
+	entries, err := filepath.Glob(filepath.Join(k.(*knit).cfg.CacheDir, "*", "*"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	err = os.WriteFile(entries[0], []byte("\ntype Cached struct{}\n"), 0644)
+	assert.NoError(t, err)
+
+	cached, err := k.ProcessText(text)
+	assert.NoError(t, err)
+	assert.NotEqual(t, generated, cached)
+	assert.Contains(t, cached, "type Cached struct{}")
+}
+
+func Test_Cache_BestEffort(t *testing.T) {
+	text := fromFile(t, "./testdata/stale")
+
+	// the cache directory cannot be created below a file
+	dir := filepath.Join(t.TempDir(), "file")
+	err := os.WriteFile(dir, nil, 0644)
+	assert.NoError(t, err)
+
+	k := New(&Config{Cache: true, CacheDir: dir, Version: "test"})
+	generated, err := k.ProcessText(text)
+	assert.NoError(t, err)
+	assert.NotEqual(t, text, generated)
+
+	for _, cfg := range []*Config{
+		{Cache: true, DryRun: true},
+		{Cache: true, Diff: true},
+	} {
+		cfg.CacheDir = t.TempDir()
+		cfg.Version = "test"
+
+		_, err := New(cfg).ProcessText(text)
+		assert.NoError(t, err)
+
+		entries, err := filepath.Glob(filepath.Join(cfg.CacheDir, "*", "*"))
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	}
+}
+
+func Test_CacheDir(t *testing.T) {
+	root := t.TempDir()
+
+	k := New(&Config{
+		Cache:    true,
+		CacheDir: DefaultCacheDir,
+		Root:     root,
+		Version:  "test",
+	})
+
+	_, err := k.ProcessText(fromFile(t, "./testdata/stale"))
+	assert.NoError(t, err)
+
+	entries, err := filepath.Glob(filepath.Join(root, DefaultCacheDir, "*", "*"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func Test_GeneratorConfig(t *testing.T) {
+	cfg := &Config{
+		Verbose:      true,
+		Loaders:      map[string]string{"spec": "openapi3"},
+		Templates:    []string{"templates"},
+		Partials:     []string{"partials/*.tmpl"},
+		ImportPaths:  []string{"proto"},
+		RestrictRefs: true,
+		Root:         "/project",
+	}
+
+	assert.Equal(t, &generator.Config{
+		Loaders:       cfg.Loaders,
+		TemplatePaths: cfg.Templates,
+		Partials:      cfg.Partials,
+		ImportPaths:   cfg.ImportPaths,
+		RefRoot:       "/project",
+		Verbose:       true,
+	}, cfg.GeneratorConfig())
+
+	cfg.RestrictRefs = false
+	assert.Empty(t, cfg.GeneratorConfig().RefRoot)
+}
+
+func Test_ProcessFilesContext(t *testing.T) {
+	files := make([]string, 0)
+	for i := 0; i < 50; i++ {
+		files = append(files, "./testdata/empty", "./testdata/stale")
+	}
+
+	t.Run("reports results in order", func(t *testing.T) {
+		k := New(&Config{Parallel: true, Jobs: 4, Ordered: true, DryRun: true})
+
+		reported := make([]string, 0, len(files))
+		k.ProcessFilesContext(context.Background(), files, func(res ProcessResult) {
+			reported = append(reported, res.File)
+		})
+
+		assert.Equal(t, files, reported)
+	})
+
+	t.Run("skips files after cancellation", func(t *testing.T) {
+		k := New(&Config{Parallel: true, Jobs: 2, DryRun: true})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		reported := 0
+		k.ProcessFilesContext(ctx, files, func(res ProcessResult) {
+			reported++
+		})
+
+		assert.Equal(t, 0, reported)
+	})
+
+	t.Run("reports files processed before failing fast in order", func(t *testing.T) {
+		failing := make([]string, 0)
+		for i := 0; i < 20; i++ {
+			failing = append(failing, "./testdata/empty")
+		}
+		failing = append(failing, "./testdata/no_options")
+		for i := 0; i < 100; i++ {
+			failing = append(failing, "./testdata/empty")
+		}
+
+		k := New(&Config{Parallel: true, Jobs: 4, Ordered: true, DryRun: true, FailFast: true})
+
+		reported := make([]string, 0, len(failing))
+		k.ProcessFilesContext(context.Background(), failing, func(res ProcessResult) {
+			reported = append(reported, res.File)
+		})
+
+		// files skipped after the failure must not hold back the results
+		// of the files processed before it
+		assert.Contains(t, reported, "./testdata/no_options")
+	})
+}
+
+func Test_Watch(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		assert.NoError(t, err)
+	}
+	annotate := func(name, input string) string {
+		write(name, fmt.Sprintf("/*\n  @knit input %s\n  @knit template tmpl`\n    {{ .name }}\n  `\n*/\n// @+knit\n// @!knit\n", filepath.Join(dir, input)))
+		return filepath.Join(dir, name)
+	}
+
+	write("a.json", `{"name": "a1"}`)
+	write("b.json", `{"name": "b1"}`)
+	a := annotate("a.txt", "a.json")
+	b := annotate("b.txt", "b.json")
+
+	k := New(&Config{Debounce: 100 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan ProcessResult, 16)
+	done := make(chan error)
+	go func() {
+		done <- k.Watch(ctx, []string{a, b}, func(res ProcessResult) {
+			results <- res
+		})
+	}()
+
+	// other methods may be called while watching
+	go func() {
+		for ctx.Err() == nil {
+			_, _ = k.Dependencies(a)
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	// run returns the files processed by the next run, and makes sure no
+	// further run follows, e.g. for the files written by knit
+	run := func(n int) []string {
+		files := make([]string, 0, n)
+		for len(files) < n {
+			select {
+			case res := <-results:
+				assert.NoError(t, res.Error)
+				files = append(files, res.File)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("expected %d processed files, got %v", n, files)
+			}
+		}
+		select {
+		case res := <-results:
+			t.Fatalf("unexpected run processing %s", res.File)
+		case <-time.After(500 * time.Millisecond):
+		}
+		sort.Strings(files)
+		return files
+	}
+
+	assert.Equal(t, []string{a, b}, run(2))
+	assert.Contains(t, fromFile(t, a), "a1")
+
+	// only files depending on a changed input are processed again
+	write("a.json", `{"name": "a2"}`)
+	assert.Equal(t, []string{a}, run(1))
+	assert.Contains(t, fromFile(t, a), "a2")
+
+	// bursts of changes are processed in a single run
+	for i := 3; i < 8; i++ {
+		write("a.json", fmt.Sprintf(`{"name": "a%d"}`, i))
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.Equal(t, []string{a}, run(1))
+	assert.Contains(t, fromFile(t, a), "a7")
+	assert.Contains(t, fromFile(t, b), "b1")
+
+	cancel()
+	assert.NoError(t, <-done)
+}
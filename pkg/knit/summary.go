@@ -0,0 +1,44 @@
+package knit
+
+import (
+	"fmt"
+	"time"
+)
+
+// Summary aggregates the results of processing multiple files. A Summary is
+// not safe for concurrent use.
+type Summary struct {
+	// Modified is the number of files modified during processing
+	Modified int
+	// Unchanged is the number of files processed without modification
+	Unchanged int
+	// Failed are the results of all files that failed to process
+	Failed []ProcessResult
+	// Time is the total time spent processing files
+	Time time.Duration
+}
+
+// Add records the result of a processed file
+func (s *Summary) Add(res ProcessResult) {
+	switch {
+	case res.Error != nil:
+		s.Failed = append(s.Failed, res)
+	case res.Modified:
+		s.Modified++
+	default:
+		s.Unchanged++
+	}
+}
+
+// Processed returns the total number of processed files
+func (s *Summary) Processed() int {
+	return s.Modified + s.Unchanged + len(s.Failed)
+}
+
+// String formats the summary as a single line for logging
+func (s *Summary) String() string {
+	return fmt.Sprintf(
+		"knit processed %d files in %s: %d modified, %d unchanged, %d failed",
+		s.Processed(), s.Time.Round(time.Millisecond), s.Modified, s.Unchanged, len(s.Failed),
+	)
+}
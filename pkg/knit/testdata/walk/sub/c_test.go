@@ -0,0 +1,4 @@
+package walk
+
+// @+knit
+// @!knit
@@ -0,0 +1,242 @@
+package knit
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/knitcodegen/knit/pkg/generator"
+	"github.com/pkg/errors"
+)
+
+// watcher tracks annotated files and the inputs and templates they depend on
+type watcher struct {
+	k  *knit
+	fs *fsnotify.Watcher
+
+	// files maps the absolute path of annotated files to the path they
+	// were provided with
+	files map[string]string
+	// deps maps annotated files to the absolute paths of their dependencies
+	deps map[string][]string
+	// dependents maps the absolute path of a dependency to the annotated
+	// files depending on it
+	dependents map[string]map[string]bool
+	// dirs are the directories added to the file system watcher
+	dirs map[string]bool
+
+	// written holds the modification time of files written by knit so the
+	// resulting events do not trigger another run
+	mu      sync.Mutex
+	written map[string]time.Time
+}
+
+// Watch processes the given files and then keeps watching them, along with
+// every input and template file their generators read. Whenever any of them
+// change on disk, only the affected files are processed again. Bursts of
+// changes are collected until no further change happens for the configured
+// debounce duration. Watch blocks until the context is cancelled.
+func (k *knit) Watch(ctx context.Context, files []string, fn OnFileProcessed) error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "failed to create file watcher")
+	}
+	defer fsw.Close()
+
+	w := &watcher{
+		k:          k,
+		fs:         fsw,
+		files:      map[string]string{},
+		deps:       map[string][]string{},
+		dependents: map[string]map[string]bool{},
+		dirs:       map[string]bool{},
+		written:    map[string]time.Time{},
+	}
+
+	for _, file := range files {
+		err := w.track(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	k.run().ProcessFilesContext(ctx, files, w.onFileProcessed(fn))
+
+	var (
+		pending = map[string]bool{}
+		timeout <-chan time.Time
+	)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			return errors.Wrap(err, "file watcher failed")
+
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+
+			affected := w.affected(event.Name)
+			if len(affected) == 0 {
+				continue
+			}
+
+			for _, file := range affected {
+				pending[file] = true
+			}
+			timeout = time.After(k.cfg.Debounce)
+
+		case <-timeout:
+			changed := make([]string, 0, len(pending))
+			for file := range pending {
+				changed = append(changed, file)
+			}
+			sort.Strings(changed)
+
+			pending = map[string]bool{}
+			timeout = nil
+
+			if k.cfg.Verbose {
+				log.Printf("knit detected changes affecting %d files", len(changed))
+			}
+
+			k.run().ProcessFilesContext(ctx, changed, w.onFileProcessed(fn))
+
+			// options may have changed, so dependencies are tracked again
+			for _, file := range changed {
+				err := w.track(file)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// run returns a copy of knit for a single run in watch mode. Every run
+// starts with an empty schema cache, so changes to files imported by inputs
+// are picked up without changing the cache of the knit instance.
+func (k *knit) run() *knit {
+	return &knit{
+		cfg:     k.cfg,
+		schemas: generator.NewSchemaCache(),
+	}
+}
+
+// track watches an annotated file and all of its current dependencies
+func (w *watcher) track(file string) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return errors.Wrap(err, "failed to resolve absolute path to file")
+	}
+	w.files[abs] = file
+
+	for _, dep := range w.deps[abs] {
+		delete(w.dependents[dep], abs)
+	}
+	w.deps[abs] = nil
+
+	paths := []string{abs}
+
+	// files with invalid options are still watched, processing them
+	// reports the error
+	deps, err := w.k.Dependencies(file)
+	if err != nil && w.k.cfg.Verbose {
+		log.Printf("knit failed to resolve dependencies of file: %s\n%+v", file, err)
+	}
+
+	for _, dep := range deps {
+		if w.dependents[dep.Path] == nil {
+			w.dependents[dep.Path] = map[string]bool{}
+		}
+		w.dependents[dep.Path][abs] = true
+		w.deps[abs] = append(w.deps[abs], dep.Path)
+		paths = append(paths, dep.Path)
+	}
+
+	// directories are watched instead of files so changes made by
+	// editors replacing files are not missed
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		if w.dirs[dir] {
+			continue
+		}
+
+		err := w.fs.Add(dir)
+		if err != nil {
+			return errors.Wrapf(err, "failed to watch directory %s", dir)
+		}
+		w.dirs[dir] = true
+	}
+
+	return nil
+}
+
+// affected returns the annotated files that need to be processed again
+// after the given path changed
+func (w *watcher) affected(path string) []string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+
+	affected := make([]string, 0)
+
+	if file, ok := w.files[abs]; ok && !w.writtenByKnit(abs) {
+		affected = append(affected, file)
+	}
+
+	for dependent := range w.dependents[abs] {
+		affected = append(affected, w.files[dependent])
+	}
+
+	return affected
+}
+
+// writtenByKnit reports whether the file is unchanged since knit last wrote it
+func (w *watcher) writtenByKnit(abs string) bool {
+	info, err := os.Stat(abs)
+	if err != nil {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	written, ok := w.written[abs]
+	return ok && written.Equal(info.ModTime())
+}
+
+// onFileProcessed wraps the callback to record files written by knit
+func (w *watcher) onFileProcessed(fn OnFileProcessed) OnFileProcessed {
+	return func(res ProcessResult) {
+		if res.Modified && res.Error == nil && !w.k.cfg.DryRun && !w.k.cfg.Diff {
+			if abs, err := filepath.Abs(res.File); err == nil {
+				if info, err := os.Stat(abs); err == nil {
+					w.mu.Lock()
+					w.written[abs] = info.ModTime()
+					w.mu.Unlock()
+				}
+			}
+		}
+
+		if fn != nil {
+			fn(res)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package loader
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// CSVLoader loads CSV files into a list of rows, each mapping the column
+// names of the header row to the values of the row. Columns holding only
+// integers, floats or booleans are converted to those types.
+type CSVLoader struct {
+	// Delimiter separates the fields of a row, a comma by default. The
+	// names "tab" and "\t" select a tab.
+	Delimiter string
+}
+
+func (l *CSVLoader) LoadFromData(data []byte) (interface{}, error) {
+	delimiter, err := l.delimiter()
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	reader.Comma = delimiter
+	reader.TrimLeadingSpace = !unicode.IsSpace(delimiter)
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal csv")
+	}
+	if len(records) == 0 {
+		return nil, errors.New("failed to unmarshal csv: missing header row")
+	}
+
+	header := records[0]
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		header[i] = strings.TrimSpace(name)
+		if len(header[i]) == 0 {
+			return nil, fmt.Errorf("failed to unmarshal csv: column %d has no name", i+1)
+		}
+		if seen[header[i]] {
+			return nil, fmt.Errorf("failed to unmarshal csv: duplicate column %s", header[i])
+		}
+		seen[header[i]] = true
+	}
+
+	records = records[1:]
+	columns := make([][]interface{}, len(header))
+	for i := range header {
+		columns[i] = inferColumn(records, i)
+	}
+
+	rows := make([]map[string]interface{}, 0, len(records))
+	for r := range records {
+		row := make(map[string]interface{}, len(header))
+		for i, name := range header {
+			row[name] = columns[i][r]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (l *CSVLoader) LoadFromFile(location string) (interface{}, error) {
+	byt, err := os.ReadFile(location)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read csv file")
+	}
+	return l.LoadFromData(byt)
+}
+
+// delimiter returns the configured field delimiter
+func (l *CSVLoader) delimiter() (rune, error) {
+	switch l.Delimiter {
+	case "":
+		return ',', nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+
+	r, size := utf8.DecodeRuneInString(l.Delimiter)
+	if size != len(l.Delimiter) || r == utf8.RuneError || r == '"' || r == '\r' || r == '\n' {
+		return 0, fmt.Errorf("invalid csv delimiter %q", l.Delimiter)
+	}
+	return r, nil
+}
+
+// inferColumn returns the values of a column converted to int64, float64
+// or bool if all of its non-empty fields hold one. Empty fields of such
+// columns are nil. Integers with leading zeros, such as postal codes, keep
+// the column a string column.
+func inferColumn(records [][]string, column int) []interface{} {
+	parsers := []func(string) (interface{}, bool){parseInt, parseFloat, parseBool}
+
+	for _, parse := range parsers {
+		values := make([]interface{}, len(records))
+		ok, found := true, false
+		for r, record := range records {
+			field := strings.TrimSpace(record[column])
+			if len(field) == 0 {
+				continue
+			}
+			if values[r], ok = parse(field); !ok {
+				break
+			}
+			found = true
+		}
+		if ok && found {
+			return values
+		}
+	}
+
+	values := make([]interface{}, len(records))
+	for r, record := range records {
+		values[r] = record[column]
+	}
+	return values
+}
+
+func parseInt(field string) (interface{}, bool) {
+	digits := strings.TrimLeft(field, "+-")
+	if len(digits) > 1 && digits[0] == '0' {
+		return nil, false
+	}
+	i, err := strconv.ParseInt(field, 10, 64)
+	return i, err == nil
+}
+
+func parseFloat(field string) (interface{}, bool) {
+	digits := strings.TrimLeft(field, "+-")
+	if len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+		return nil, false
+	}
+	f, err := strconv.ParseFloat(field, 64)
+	return f, err == nil && !math.IsInf(f, 0) && !math.IsNaN(f)
+}
+
+func parseBool(field string) (interface{}, bool) {
+	switch strings.ToLower(field) {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	return nil, false
+}
@@ -0,0 +1,29 @@
+package loader
+
+import (
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
+)
+
+// DotenvLoader loads environment files into a map of variable names to
+// their values. Variables referenced as ${NAME} are expanded using the
+// variables defined earlier in the same file.
+type DotenvLoader struct{}
+
+func (l *DotenvLoader) LoadFromData(data []byte) (interface{}, error) {
+	out, err := godotenv.Unmarshal(string(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal dotenv")
+	}
+	return out, nil
+}
+
+func (l *DotenvLoader) LoadFromFile(location string) (interface{}, error) {
+	byt, err := os.ReadFile(location)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read dotenv file")
+	}
+	return l.LoadFromData(byt)
+}
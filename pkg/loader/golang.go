@@ -0,0 +1,689 @@
+package loader
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/packages"
+)
+
+// GoLoader loads a Go package including its type information. Input files
+// and directories load the package they belong to, input literals are
+// package patterns such as import paths.
+type GoLoader struct{}
+
+// GoPackage is a Go package and the declarations of its package scope, in
+// the order they are declared in
+type GoPackage struct {
+	Name string
+	// Path is the import path of the package
+	Path string
+	Dir  string
+	Doc  string
+	// Types are all named types of the package
+	Types      []*GoType
+	Structs    []*GoStruct
+	Interfaces []*GoInterface
+	Constants  []*GoConst
+	Functions  []*GoFunc
+	// Files are the absolute paths of the Go files of the package
+	Files []string
+}
+
+// GoType is a named type
+type GoType struct {
+	Name     string
+	Doc      string
+	Exported bool
+	// Kind is the kind of the underlying type, e.g. struct, interface,
+	// basic, slice, map, pointer, func, array or chan
+	Kind string
+	// Underlying is the underlying type
+	Underlying string
+	// Methods are the methods declared on the type
+	Methods []*GoFunc
+}
+
+// GoStruct is a named struct type
+type GoStruct struct {
+	*GoType
+	Fields []*GoField
+}
+
+// GoField is a field of a struct
+type GoField struct {
+	Name string
+	// Type is the type of the field, qualified with the package name if it
+	// is declared in another package
+	Type string
+	Doc  string
+	// Comment is the line comment of the field
+	Comment  string
+	Tag      string
+	Embedded bool
+	Exported bool
+}
+
+// GoInterface is a named interface type
+type GoInterface struct {
+	*GoType
+	// Methods are all methods of the interface, including the methods of
+	// embedded interfaces
+	Methods []*GoFunc
+	// Embeds are the embedded types
+	Embeds []string
+}
+
+// GoFunc is a function, method or interface method
+type GoFunc struct {
+	Name     string
+	Doc      string
+	Exported bool
+	Params   []*GoParam
+	Results  []*GoParam
+	Variadic bool
+	// Receiver is the receiver type of methods, e.g. *User
+	Receiver string
+	// PointerReceiver is set for methods with pointer receivers
+	PointerReceiver bool
+	// Signature is the signature without the func keyword and name, e.g.
+	// (ctx context.Context) error
+	Signature string
+}
+
+// GoParam is a parameter or result of a function
+type GoParam struct {
+	Name string
+	Type string
+}
+
+// GoConst is a constant
+type GoConst struct {
+	Name     string
+	Doc      string
+	Exported bool
+	Type     string
+	// Value is the exact value of the constant as Go source
+	Value string
+}
+
+// Lookup returns the value of the given key in the struct tag of the field
+func (f *GoField) Lookup(key string) string {
+	return reflect.StructTag(f.Tag).Get(key)
+}
+
+// Struct returns the struct type with the given name
+func (p *GoPackage) Struct(name string) *GoStruct {
+	for _, s := range p.Structs {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Interface returns the interface type with the given name
+func (p *GoPackage) Interface(name string) *GoInterface {
+	for _, i := range p.Interfaces {
+		if i.Name == name {
+			return i
+		}
+	}
+	return nil
+}
+
+// Type returns the named type with the given name
+func (p *GoPackage) Type(name string) *GoType {
+	for _, t := range p.Types {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func (l *GoLoader) LoadFromData(data []byte) (interface{}, error) {
+	return l.load("", strings.TrimSpace(string(data)))
+}
+
+func (l *GoLoader) LoadFromFile(location string) (interface{}, error) {
+	dir, err := packageDir(location)
+	if err != nil {
+		return nil, err
+	}
+	return l.load(dir, ".")
+}
+
+// ResolveImports returns the paths of the Go files of the package matching
+// the pattern
+func (l *GoLoader) ResolveImports(data []byte) ([]string, error) {
+	return l.files("", strings.TrimSpace(string(data)))
+}
+
+// ResolveFileImports returns the paths of the Go files of the package the
+// given file or directory belongs to
+func (l *GoLoader) ResolveFileImports(location string) ([]string, error) {
+	dir, err := packageDir(location)
+	if err != nil {
+		return nil, err
+	}
+	return l.files(dir, ".")
+}
+
+// packageDir returns the directory of the package a file or directory
+// belongs to
+func packageDir(location string) (string, error) {
+	info, err := os.Stat(location)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to stat go package")
+	}
+	if info.IsDir() {
+		return location, nil
+	}
+	return filepath.Dir(location), nil
+}
+
+func (l *GoLoader) load(dir, pattern string) (*GoPackage, error) {
+	pkg, err := loadPackage(dir, pattern, packages.NeedName|packages.NeedFiles|packages.NeedImports|packages.NeedDeps)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPackage(pkg); err != nil {
+		return nil, err
+	}
+
+	g := &goPackageLoader{
+		pkg:  pkg,
+		docs: map[token.Pos]goDoc{},
+	}
+	return g.load(), nil
+}
+
+// checkPackage parses and type checks the package along with all of its
+// dependencies. go/packages of x/tools 0.1.10 can neither read the export
+// data of newer compilers nor type check with their sizes, so packages are
+// type checked here, each dependency once and concurrently.
+func checkPackage(pkg *packages.Package) error {
+	c := &goChecker{
+		fset:   token.NewFileSet(),
+		sizes:  types.SizesFor("gc", runtime.GOARCH),
+		checks: map[string]*goCheck{},
+		root:   pkg,
+	}
+
+	chk := c.check(pkg)
+	<-chk.done
+	if chk.err != nil {
+		return chk.err
+	}
+
+	pkg.Fset = c.fset
+	pkg.Syntax = chk.files
+	pkg.Types = chk.pkg
+	return nil
+}
+
+// goChecker type checks a package and its dependencies from source
+type goChecker struct {
+	fset  *token.FileSet
+	sizes types.Sizes
+	root  *packages.Package
+
+	mu     sync.Mutex
+	checks map[string]*goCheck
+}
+
+// goCheck is the type check of a package, done once done is closed
+type goCheck struct {
+	done  chan struct{}
+	pkg   *types.Package
+	files []*ast.File
+	err   error
+}
+
+// check starts to type check the package, unless it already was
+func (c *goChecker) check(pkg *packages.Package) *goCheck {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if chk, ok := c.checks[pkg.ID]; ok {
+		return chk
+	}
+	chk := &goCheck{done: make(chan struct{})}
+	c.checks[pkg.ID] = chk
+
+	go func() {
+		defer close(chk.done)
+		chk.pkg, chk.files, chk.err = c.run(pkg)
+	}()
+	return chk
+}
+
+// run parses and type checks the package once its imports are checked.
+// Only the declarations of dependencies are checked, and their errors are
+// ignored as long as the package itself type checks.
+func (c *goChecker) run(pkg *packages.Package) (*types.Package, []*ast.File, error) {
+	if pkg.PkgPath == "unsafe" {
+		return types.Unsafe, nil, nil
+	}
+
+	imports := make(map[string]*goCheck, len(pkg.Imports))
+	for path, imp := range pkg.Imports {
+		imports[path] = c.check(imp)
+	}
+
+	root := pkg == c.root
+	mode := parser.Mode(0)
+	if root {
+		mode = parser.ParseComments
+	}
+
+	files := make([]*ast.File, 0, len(pkg.GoFiles))
+	for _, path := range goFiles(pkg) {
+		file, err := parser.ParseFile(c.fset, path, nil, mode)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to parse go package")
+		}
+		files = append(files, file)
+	}
+
+	for _, chk := range imports {
+		<-chk.done
+	}
+
+	conf := types.Config{
+		Importer: goImporter(func(path string) (*types.Package, error) {
+			chk, ok := imports[path]
+			if !ok {
+				return nil, fmt.Errorf("package %s is not imported by %s", path, pkg.PkgPath)
+			}
+			if chk.pkg == nil {
+				return nil, chk.err
+			}
+			return chk.pkg, nil
+		}),
+		Sizes:            c.sizes,
+		FakeImportC:      true,
+		IgnoreFuncBodies: !root,
+	}
+	if !root {
+		conf.Error = func(error) {}
+	}
+
+	typesPkg, err := conf.Check(pkg.PkgPath, c.fset, files, nil)
+	if err != nil && root {
+		return nil, nil, errors.Wrap(err, "failed to type check go package")
+	}
+	return typesPkg, files, nil
+}
+
+// goImporter imports packages with a function
+type goImporter func(path string) (*types.Package, error)
+
+func (i goImporter) Import(path string) (*types.Package, error) {
+	return i(path)
+}
+
+// files returns the absolute paths of the Go files of the package
+func (l *GoLoader) files(dir, pattern string) ([]string, error) {
+	pkg, err := loadPackage(dir, pattern, packages.NeedName|packages.NeedFiles)
+	if err != nil {
+		return nil, err
+	}
+	return goFiles(pkg), nil
+}
+
+// loadPackage loads the single package matching the pattern, relative to
+// the given directory
+func loadPackage(dir, pattern string, mode packages.LoadMode) (*packages.Package, error) {
+	if len(pattern) == 0 {
+		return nil, errors.New("missing go package pattern")
+	}
+
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: mode,
+		Dir:  dir,
+	}, pattern)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load go package")
+	}
+
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("go package pattern %s matches %d packages", pattern, len(pkgs))
+	}
+	pkg := pkgs[0]
+
+	if len(pkg.Errors) != 0 {
+		return nil, errors.Wrap(pkg.Errors[0], "failed to load go package")
+	}
+	return pkg, nil
+}
+
+// goFiles returns the sorted absolute paths of the Go files of a package
+func goFiles(pkg *packages.Package) []string {
+	files := make([]string, 0, len(pkg.GoFiles))
+	for _, file := range pkg.GoFiles {
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// goDoc holds the comments of a declaration
+type goDoc struct {
+	doc     string
+	comment string
+}
+
+// goPackageLoader builds the model of a loaded package
+type goPackageLoader struct {
+	pkg *packages.Package
+	// docs maps the positions of declared identifiers to their comments
+	docs map[token.Pos]goDoc
+}
+
+func (g *goPackageLoader) load() *GoPackage {
+	out := &GoPackage{
+		Name:       g.pkg.Name,
+		Path:       g.pkg.PkgPath,
+		Types:      make([]*GoType, 0),
+		Structs:    make([]*GoStruct, 0),
+		Interfaces: make([]*GoInterface, 0),
+		Constants:  make([]*GoConst, 0),
+		Functions:  make([]*GoFunc, 0),
+		Files:      goFiles(g.pkg),
+	}
+
+	if len(out.Files) != 0 {
+		out.Dir = filepath.Dir(out.Files[0])
+	}
+
+	for _, file := range g.pkg.Syntax {
+		if file.Doc != nil && len(out.Doc) == 0 {
+			out.Doc = strings.TrimSpace(file.Doc.Text())
+		}
+		g.collectDocs(file)
+	}
+
+	scope := g.pkg.Types.Scope()
+	objects := make([]types.Object, 0, len(scope.Names()))
+	for _, name := range scope.Names() {
+		objects = append(objects, scope.Lookup(name))
+	}
+	g.sortByPosition(objects)
+
+	for _, obj := range objects {
+		switch obj := obj.(type) {
+		case *types.TypeName:
+			typ := g.namedType(obj)
+			out.Types = append(out.Types, typ)
+
+			switch underlying := obj.Type().Underlying().(type) {
+			case *types.Struct:
+				out.Structs = append(out.Structs, &GoStruct{
+					GoType: typ,
+					Fields: g.fields(underlying),
+				})
+			case *types.Interface:
+				out.Interfaces = append(out.Interfaces, g.iface(typ, underlying))
+			}
+
+		case *types.Const:
+			out.Constants = append(out.Constants, &GoConst{
+				Name:     obj.Name(),
+				Doc:      g.docs[obj.Pos()].doc,
+				Exported: obj.Exported(),
+				Type:     g.typeString(obj.Type()),
+				Value:    obj.Val().ExactString(),
+			})
+
+		case *types.Func:
+			out.Functions = append(out.Functions, g.function(obj))
+		}
+	}
+
+	return out
+}
+
+// collectDocs records the comments of all declarations in the file
+func (g *goPackageLoader) collectDocs(file *ast.File) {
+	record := func(pos token.Pos, docs ...*ast.CommentGroup) {
+		d := goDoc{}
+		for _, doc := range docs {
+			if doc != nil {
+				d.doc = strings.TrimSpace(doc.Text())
+				break
+			}
+		}
+		g.docs[pos] = d
+	}
+
+	recordFields := func(fields *ast.FieldList) {
+		if fields == nil {
+			return
+		}
+		for _, field := range fields.List {
+			d := goDoc{}
+			if field.Doc != nil {
+				d.doc = strings.TrimSpace(field.Doc.Text())
+			}
+			if field.Comment != nil {
+				d.comment = strings.TrimSpace(field.Comment.Text())
+			}
+
+			for _, name := range field.Names {
+				g.docs[name.Pos()] = d
+			}
+			if len(field.Names) == 0 {
+				g.docs[embeddedPos(field.Type)] = d
+			}
+		}
+	}
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			record(decl.Name.Pos(), decl.Doc)
+
+		case *ast.GenDecl:
+			// the declaration comment documents ungrouped specs
+			var declDoc *ast.CommentGroup
+			if !decl.Lparen.IsValid() {
+				declDoc = decl.Doc
+			}
+
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					record(spec.Name.Pos(), spec.Doc, declDoc)
+
+					switch typ := spec.Type.(type) {
+					case *ast.StructType:
+						recordFields(typ.Fields)
+					case *ast.InterfaceType:
+						recordFields(typ.Methods)
+					}
+
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						record(name.Pos(), spec.Doc, declDoc)
+					}
+				}
+			}
+		}
+	}
+}
+
+// embeddedPos returns the position of the type name of an embedded field
+func embeddedPos(expr ast.Expr) token.Pos {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedPos(expr.X)
+	case *ast.SelectorExpr:
+		return expr.Sel.Pos()
+	}
+	return expr.Pos()
+}
+
+func (g *goPackageLoader) sortByPosition(objects []types.Object) {
+	fset := g.pkg.Fset
+	sort.SliceStable(objects, func(i, j int) bool {
+		a, b := fset.Position(objects[i].Pos()), fset.Position(objects[j].Pos())
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+}
+
+// typeString formats a type, qualifying types of other packages with their
+// package name
+func (g *goPackageLoader) typeString(typ types.Type) string {
+	return types.TypeString(typ, func(pkg *types.Package) string {
+		if pkg == g.pkg.Types {
+			return ""
+		}
+		return pkg.Name()
+	})
+}
+
+func (g *goPackageLoader) namedType(obj *types.TypeName) *GoType {
+	typ := &GoType{
+		Name:       obj.Name(),
+		Doc:        g.docs[obj.Pos()].doc,
+		Exported:   obj.Exported(),
+		Kind:       typeKind(obj.Type().Underlying()),
+		Underlying: g.typeString(obj.Type().Underlying()),
+		Methods:    make([]*GoFunc, 0),
+	}
+
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		// aliases have no methods of their own
+		return typ
+	}
+
+	methods := make([]types.Object, 0, named.NumMethods())
+	for i := 0; i < named.NumMethods(); i++ {
+		methods = append(methods, named.Method(i))
+	}
+	g.sortByPosition(methods)
+
+	for _, method := range methods {
+		typ.Methods = append(typ.Methods, g.function(method.(*types.Func)))
+	}
+
+	return typ
+}
+
+// typeKind returns the kind of an underlying type
+func typeKind(typ types.Type) string {
+	switch typ.(type) {
+	case *types.Struct:
+		return "struct"
+	case *types.Interface:
+		return "interface"
+	case *types.Basic:
+		return "basic"
+	case *types.Slice:
+		return "slice"
+	case *types.Array:
+		return "array"
+	case *types.Map:
+		return "map"
+	case *types.Pointer:
+		return "pointer"
+	case *types.Signature:
+		return "func"
+	case *types.Chan:
+		return "chan"
+	}
+	return ""
+}
+
+func (g *goPackageLoader) fields(s *types.Struct) []*GoField {
+	fields := make([]*GoField, 0, s.NumFields())
+	for i := 0; i < s.NumFields(); i++ {
+		field := s.Field(i)
+		doc := g.docs[field.Pos()]
+
+		fields = append(fields, &GoField{
+			Name:     field.Name(),
+			Type:     g.typeString(field.Type()),
+			Doc:      doc.doc,
+			Comment:  doc.comment,
+			Tag:      s.Tag(i),
+			Embedded: field.Embedded(),
+			Exported: field.Exported(),
+		})
+	}
+	return fields
+}
+
+func (g *goPackageLoader) iface(typ *GoType, i *types.Interface) *GoInterface {
+	out := &GoInterface{
+		GoType:  typ,
+		Methods: make([]*GoFunc, 0, i.NumMethods()),
+		Embeds:  make([]string, 0, i.NumEmbeddeds()),
+	}
+
+	for j := 0; j < i.NumEmbeddeds(); j++ {
+		out.Embeds = append(out.Embeds, g.typeString(i.EmbeddedType(j)))
+	}
+
+	// methods are sorted by name, like the method set of the interface
+	for j := 0; j < i.NumMethods(); j++ {
+		out.Methods = append(out.Methods, g.function(i.Method(j)))
+	}
+
+	return out
+}
+
+func (g *goPackageLoader) function(fn *types.Func) *GoFunc {
+	sig := fn.Type().(*types.Signature)
+
+	out := &GoFunc{
+		Name:      fn.Name(),
+		Doc:       g.docs[fn.Pos()].doc,
+		Exported:  fn.Exported(),
+		Params:    g.params(sig.Params()),
+		Results:   g.params(sig.Results()),
+		Variadic:  sig.Variadic(),
+		Signature: strings.TrimPrefix(g.typeString(sig), "func"),
+	}
+
+	if recv := sig.Recv(); recv != nil {
+		if _, ok := recv.Type().Underlying().(*types.Interface); !ok {
+			out.Receiver = g.typeString(recv.Type())
+			_, out.PointerReceiver = recv.Type().(*types.Pointer)
+		}
+	}
+
+	return out
+}
+
+func (g *goPackageLoader) params(tuple *types.Tuple) []*GoParam {
+	params := make([]*GoParam, 0, tuple.Len())
+	for i := 0; i < tuple.Len(); i++ {
+		param := tuple.At(i)
+		params = append(params, &GoParam{
+			Name: param.Name(),
+			Type: g.typeString(param.Type()),
+		})
+	}
+	return params
+}
@@ -1,9 +1,13 @@
 package loader
 
 import (
+	"os"
+
 	"github.com/pkg/errors"
+	"github.com/vektah/gqlparser/v2"
 	"github.com/vektah/gqlparser/v2/ast"
 	"github.com/vektah/gqlparser/v2/parser"
+	"github.com/vektah/gqlparser/v2/validator"
 )
 
 type GraphqlLoader struct {
@@ -19,3 +23,62 @@ func (g *GraphqlLoader) LoadFromData(data []byte) (interface{}, error) {
 	}
 	return doc, nil
 }
+
+// GraphqlSchemaLoader validates a GraphQL schema and loads it including the
+// built-in scalars and directives, with all type extensions merged. Operation
+// documents are validated against the schema and loaded alongside it.
+type GraphqlSchemaLoader struct {
+	// Operations are the paths to documents holding operations and fragments
+	Operations []string
+}
+
+// GraphqlSchema is a validated GraphQL schema and the operations defined
+// against it
+type GraphqlSchema struct {
+	*ast.Schema
+	// Operations are the operations of all operation documents
+	Operations ast.OperationList
+	// Fragments are the fragments of all operation documents
+	Fragments ast.FragmentDefinitionList
+}
+
+func (g *GraphqlSchemaLoader) LoadFromData(data []byte) (interface{}, error) {
+	schema, gerr := gqlparser.LoadSchema(&ast.Source{
+		Input: string(data),
+		Name:  "spec",
+	})
+	if gerr != nil {
+		return nil, errors.Wrap(gerr, "failed to load schema")
+	}
+
+	// operations of all documents are validated together so fragments can
+	// be shared between documents
+	query := &ast.QueryDocument{}
+	for _, path := range g.Operations {
+		byt, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load operations file")
+		}
+
+		doc, gerr := parser.ParseQuery(&ast.Source{
+			Input: string(byt),
+			Name:  path,
+		})
+		if gerr != nil {
+			return nil, errors.Wrap(gerr, "failed to parse operations")
+		}
+
+		query.Operations = append(query.Operations, doc.Operations...)
+		query.Fragments = append(query.Fragments, doc.Fragments...)
+	}
+
+	if errs := validator.Validate(schema, query); len(errs) != 0 {
+		return nil, errors.Wrap(errs, "failed to validate operations")
+	}
+
+	return &GraphqlSchema{
+		Schema:     schema,
+		Operations: query.Operations,
+		Fragments:  query.Fragments,
+	}, nil
+}
@@ -0,0 +1,516 @@
+package loader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// jsonSchemaLiteral is the location JSON schema literals are loaded from
+const jsonSchemaLiteral = "schema.json"
+
+// JSONSchemaLoader loads JSON schema documents of draft 7 up to draft
+// 2020-12. References are resolved, relative to the file containing them
+// for input files or to the working directory for literals.
+type JSONSchemaLoader struct {
+	// RefRoot is the directory external references are restricted to, if set
+	RefRoot string
+}
+
+// JSONSchema is a normalized JSON schema
+type JSONSchema struct {
+	// Name is the name of the definition, or the name of the file without
+	// its extension for root schemas. Empty for inline schemas.
+	Name        string
+	Title       string
+	Description string
+	// Ref is the schema referenced by this schema, if any
+	Ref *JSONSchema
+	// Type is the first type allowed by the schema, other than null
+	Type string
+	// Types are all types allowed by the schema, other than null
+	Types []string
+	// Nullable is set if the schema allows null values
+	Nullable bool
+	Format   string
+	// Enum are the allowed values, including the value of const
+	Enum    []interface{}
+	Default interface{}
+
+	Properties           []*JSONSchemaProperty
+	Required             []string
+	AdditionalProperties *JSONSchema
+	// Items is the schema of all array items not covered by PrefixItems
+	Items *JSONSchema
+	// PrefixItems are the schemas of the leading array items
+	PrefixItems []*JSONSchema
+
+	AllOf []*JSONSchema
+	OneOf []*JSONSchema
+	AnyOf []*JSONSchema
+	Not   *JSONSchema
+
+	Pattern          string
+	MinLength        *int
+	MaxLength        *int
+	MinItems         *int
+	MaxItems         *int
+	Minimum          *float64
+	Maximum          *float64
+	ExclusiveMinimum *float64
+	ExclusiveMaximum *float64
+
+	// Definitions are the schemas defined in $defs or definitions of the
+	// document, sorted by name. Only set on the root schema.
+	Definitions []*JSONSchema
+	// Location is the absolute URL of the schema
+	Location string
+}
+
+// JSONSchemaProperty is a property of an object schema
+type JSONSchemaProperty struct {
+	*JSONSchema
+	// Name is the name of the property
+	Name string
+	// Required is set if the property is required by the object schema
+	Required bool
+}
+
+func (l *JSONSchemaLoader) LoadFromData(data []byte) (interface{}, error) {
+	location, err := filepath.Abs(jsonSchemaLiteral)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve absolute path to working directory")
+	}
+
+	return l.load(location, data)
+}
+
+func (l *JSONSchemaLoader) LoadFromFile(location string) (interface{}, error) {
+	location, err := filepath.Abs(location)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve absolute path to schema")
+	}
+
+	byt, err := os.ReadFile(location)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read json schema file")
+	}
+
+	return l.load(location, byt)
+}
+
+// ResolveFileImports returns the paths of all files referenced by the
+// schema at the given location, directly or transitively. The schema is
+// not compiled.
+func (l *JSONSchemaLoader) ResolveFileImports(location string) ([]string, error) {
+	location, err := filepath.Abs(location)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve absolute path to schema")
+	}
+
+	root, err := l.root()
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]bool{location: true}
+	pending := []string{location}
+	for len(pending) != 0 {
+		file := pending[0]
+		pending = pending[1:]
+
+		var r io.ReadCloser
+		if file == location {
+			r, err = os.Open(file)
+		} else {
+			r, err = loadJSONSchemaURL(fileURL(file), root)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read json schema file")
+		}
+
+		var doc interface{}
+		err = json.NewDecoder(r).Decode(&doc)
+		r.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal json schema %s", file)
+		}
+
+		base, err := url.Parse(fileURL(file))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to resolve json schema url")
+		}
+
+		walker := &openapiWalker{
+			fields: jsonSchemaFields,
+			ref: func(_ map[string]interface{}, ref string, _ openapiKind) error {
+				u, err := base.Parse(ref)
+				if err != nil {
+					return errors.Wrapf(err, "invalid reference %s", ref)
+				}
+				if u.Scheme != "file" {
+					return fmt.Errorf("unsupported reference %s, only local files can be referenced", ref)
+				}
+
+				path := urlPath(u)
+				if !files[path] {
+					files[path] = true
+					pending = append(pending, path)
+				}
+				return nil
+			},
+		}
+		err = walker.walk(doc, openapiSchema, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	delete(files, location)
+	refs := make([]string, 0, len(files))
+	for file := range files {
+		refs = append(refs, file)
+	}
+	sort.Strings(refs)
+	return refs, nil
+}
+
+// jsonSchemaFields are the keywords of JSON schemas holding schemas
+var jsonSchemaFields = map[openapiKind]map[string]openapiField{
+	openapiSchema: {
+		"properties":            {openapiSchema, openapiMap},
+		"patternProperties":     {openapiSchema, openapiMap},
+		"dependentSchemas":      {openapiSchema, openapiMap},
+		"dependencies":          {openapiSchema, openapiMap},
+		"definitions":           {openapiSchema, openapiMap},
+		"$defs":                 {openapiSchema, openapiMap},
+		"items":                 {openapiSchema, openapiOneOrList},
+		"additionalItems":       {openapiSchema, openapiOne},
+		"additionalProperties":  {openapiSchema, openapiOne},
+		"unevaluatedItems":      {openapiSchema, openapiOne},
+		"unevaluatedProperties": {openapiSchema, openapiOne},
+		"contains":              {openapiSchema, openapiOne},
+		"propertyNames":         {openapiSchema, openapiOne},
+		"contentSchema":         {openapiSchema, openapiOne},
+		"not":                   {openapiSchema, openapiOne},
+		"if":                    {openapiSchema, openapiOne},
+		"then":                  {openapiSchema, openapiOne},
+		"else":                  {openapiSchema, openapiOne},
+		"allOf":                 {openapiSchema, openapiList},
+		"anyOf":                 {openapiSchema, openapiList},
+		"oneOf":                 {openapiSchema, openapiList},
+		"prefixItems":           {openapiSchema, openapiList},
+	},
+}
+
+// load compiles the schema with the given contents at the given absolute
+// path and returns its normalized model
+func (l *JSONSchemaLoader) load(location string, data []byte) (*JSONSchema, error) {
+	var doc map[string]interface{}
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal json schema")
+	}
+
+	root, err := l.root()
+	if err != nil {
+		return nil, err
+	}
+
+	base := fileURL(location)
+	compiler := jsonschema.NewCompiler()
+	compiler.ExtractAnnotations = true
+	compiler.LoadURL = func(s string) (io.ReadCloser, error) {
+		return loadJSONSchemaURL(s, root)
+	}
+
+	err = compiler.AddResource(base, bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load json schema")
+	}
+
+	compiled, err := compiler.Compile(base)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compile json schema")
+	}
+
+	n := &jsonSchemaNormalizer{
+		base:    base,
+		schemas: map[*jsonschema.Schema]*JSONSchema{},
+	}
+	schema := n.normalize(compiled)
+
+	// definitions are not part of compiled schemas, so they are compiled
+	// from the document by their location
+	for _, keyword := range []string{"$defs", "definitions"} {
+		defs, ok := doc[keyword].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for name := range defs {
+			def, err := compiler.Compile(base + "#/" + keyword + "/" + escapePointer(name))
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to compile json schema definition %s", name)
+			}
+			schema.Definitions = append(schema.Definitions, n.normalize(def))
+		}
+	}
+	sort.Slice(schema.Definitions, func(i, j int) bool {
+		return schema.Definitions[i].Name < schema.Definitions[j].Name
+	})
+
+	return schema, nil
+}
+
+// root returns the absolute path of the directory references are restricted
+// to, if any
+func (l *JSONSchemaLoader) root() (string, error) {
+	if len(l.RefRoot) == 0 {
+		return "", nil
+	}
+
+	root, err := filepath.Abs(l.RefRoot)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to resolve absolute path to reference root")
+	}
+	return root, nil
+}
+
+// loadJSONSchemaURL opens a referenced schema file. Only local files inside
+// the root directory, if set, can be referenced.
+func loadJSONSchemaURL(s, root string) (io.ReadCloser, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "file" {
+		return nil, fmt.Errorf("unsupported reference %s, only local files can be referenced", s)
+	}
+
+	file := urlPath(u)
+
+	if len(root) != 0 {
+		rel, err := filepath.Rel(root, file)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("reference to %s is outside of %s", file, root)
+		}
+	}
+
+	return os.Open(file)
+}
+
+// jsonSchemaNormalizer converts compiled schemas into their normalized model
+type jsonSchemaNormalizer struct {
+	// base is the URL of the root document
+	base string
+	// schemas holds the converted schemas, so schemas referenced multiple
+	// times or recursively are converted once
+	schemas map[*jsonschema.Schema]*JSONSchema
+}
+
+func (n *jsonSchemaNormalizer) normalize(s *jsonschema.Schema) *JSONSchema {
+	if s == nil {
+		return nil
+	}
+	if schema, ok := n.schemas[s]; ok {
+		return schema
+	}
+
+	schema := &JSONSchema{
+		Name:        n.name(s),
+		Title:       s.Title,
+		Description: s.Description,
+		Format:      s.Format,
+		Enum:        s.Enum,
+		Default:     s.Default,
+		Required:    s.Required,
+		Location:    s.Location,
+		MinLength:   optionalInt(s.MinLength),
+		MaxLength:   optionalInt(s.MaxLength),
+		MinItems:    optionalInt(s.MinItems),
+		MaxItems:    optionalInt(s.MaxItems),
+		Minimum:     optionalFloat(s.Minimum),
+		Maximum:     optionalFloat(s.Maximum),
+
+		ExclusiveMinimum: optionalFloat(s.ExclusiveMinimum),
+		ExclusiveMaximum: optionalFloat(s.ExclusiveMaximum),
+	}
+	n.schemas[s] = schema
+
+	if s.Pattern != nil {
+		schema.Pattern = s.Pattern.String()
+	}
+
+	if len(s.Constant) != 0 {
+		schema.Enum = []interface{}{s.Constant[0]}
+	}
+
+	for _, typ := range s.Types {
+		if typ == "null" {
+			schema.Nullable = true
+			continue
+		}
+		schema.Types = append(schema.Types, typ)
+	}
+	if len(schema.Types) != 0 {
+		schema.Type = schema.Types[0]
+	}
+
+	schema.Ref = n.normalize(s.Ref)
+	if schema.Ref == nil {
+		schema.Ref = n.normalize(s.DynamicRef)
+	}
+	if schema.Ref == nil {
+		schema.Ref = n.normalize(s.RecursiveRef)
+	}
+
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		schema.Properties = append(schema.Properties, &JSONSchemaProperty{
+			JSONSchema: n.normalize(s.Properties[name]),
+			Name:       name,
+			Required:   contains(s.Required, name),
+		})
+	}
+
+	if additional, ok := s.AdditionalProperties.(*jsonschema.Schema); ok {
+		schema.AdditionalProperties = n.normalize(additional)
+	}
+
+	// draft 7 defines tuples using items and additionalItems, draft 2020-12
+	// using prefixItems and items
+	switch items := s.Items.(type) {
+	case *jsonschema.Schema:
+		schema.Items = n.normalize(items)
+	case []*jsonschema.Schema:
+		schema.PrefixItems = n.normalizeAll(items)
+		if additional, ok := s.AdditionalItems.(*jsonschema.Schema); ok {
+			schema.Items = n.normalize(additional)
+		}
+	}
+	if len(s.PrefixItems) != 0 {
+		schema.PrefixItems = n.normalizeAll(s.PrefixItems)
+	}
+	if s.Items2020 != nil {
+		schema.Items = n.normalize(s.Items2020)
+	}
+
+	schema.AllOf = n.normalizeAll(s.AllOf)
+	schema.OneOf = n.normalizeAll(s.OneOf)
+	schema.AnyOf = n.normalizeAll(s.AnyOf)
+	schema.Not = n.normalize(s.Not)
+
+	return schema
+}
+
+func (n *jsonSchemaNormalizer) normalizeAll(schemas []*jsonschema.Schema) []*JSONSchema {
+	if len(schemas) == 0 {
+		return nil
+	}
+
+	normalized := make([]*JSONSchema, 0, len(schemas))
+	for _, s := range schemas {
+		normalized = append(normalized, n.normalize(s))
+	}
+	return normalized
+}
+
+// name returns the name of a definition, or the file name of a root schema
+func (n *jsonSchemaNormalizer) name(s *jsonschema.Schema) string {
+	parts := strings.SplitN(s.Location, "#", 2)
+
+	ptr := ""
+	if len(parts) == 2 {
+		ptr = parts[1]
+	}
+
+	segments := strings.Split(strings.Trim(ptr, "/"), "/")
+	if len(segments) == 2 && (segments[0] == "$defs" || segments[0] == "definitions") {
+		return unescapePointer(segments[1])
+	}
+
+	if ptr == "" {
+		name := path.Base(parts[0])
+		return strings.TrimSuffix(name, path.Ext(name))
+	}
+
+	return ""
+}
+
+// fileURL returns the file URL of an absolute path
+func fileURL(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// urlPath returns the path of a file URL
+func urlPath(u *url.URL) string {
+	path := u.Path
+	if runtime.GOOS == "windows" {
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.FromSlash(path)
+}
+
+// escapePointer escapes a JSON pointer reference token
+func escapePointer(token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	token = strings.ReplaceAll(token, "/", "~1")
+	return url.PathEscape(token)
+}
+
+// unescapePointer unescapes a JSON pointer reference token
+func unescapePointer(token string) string {
+	if unescaped, err := url.PathUnescape(token); err == nil {
+		token = unescaped
+	}
+	token = strings.ReplaceAll(token, "~1", "/")
+	return strings.ReplaceAll(token, "~0", "~")
+}
+
+// optionalInt returns nil for the value the compiler uses for missing
+// limits
+func optionalInt(i int) *int {
+	if i < 0 {
+		return nil
+	}
+	return &i
+}
+
+func optionalFloat(r *big.Rat) *float64 {
+	if r == nil {
+		return nil
+	}
+	f, _ := r.Float64()
+	return &f
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -2,5 +2,26 @@ package loader
 
 type SchemaLoader interface {
 	LoadFromData(data []byte) (interface{}, error)
-	//LoadFromFile(location string) (interface{}, error)
+}
+
+// FileLoader is implemented by loaders that need to know the location of an
+// input file, e.g. to resolve references relative to it
+type FileLoader interface {
+	LoadFromFile(location string) (interface{}, error)
+}
+
+// ImportResolver is implemented by loaders whose inputs can import other
+// files, so changes to imported files can be detected
+type ImportResolver interface {
+	// ResolveImports returns the paths of all files imported by the input,
+	// directly or transitively
+	ResolveImports(data []byte) ([]string, error)
+}
+
+// FileImportResolver is implemented by loaders whose input files can
+// reference other files relative to their location
+type FileImportResolver interface {
+	// ResolveFileImports returns the paths of all files referenced by the
+	// input file, directly or transitively
+	ResolveFileImports(location string) ([]string, error)
 }
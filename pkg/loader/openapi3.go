@@ -1,11 +1,23 @@
 package loader
 
 import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
 	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/ghodss/yaml"
 	"github.com/pkg/errors"
 )
 
-type OpenAPI3Loader struct{}
+type OpenAPI3Loader struct {
+	// RefRoot is the directory external references are restricted to, if set
+	RefRoot string
+}
 
 func (l *OpenAPI3Loader) LoadFromData(data []byte) (interface{}, error) {
 	loader := openapi3.NewLoader()
@@ -20,9 +32,16 @@ func (l *OpenAPI3Loader) LoadFromData(data []byte) (interface{}, error) {
 	return doc, nil
 }
 
+// LoadFromFile loads the spec at the given location. External references
+// are resolved relative to the file containing them.
 func (l *OpenAPI3Loader) LoadFromFile(location string) (interface{}, error) {
-	loader := openapi3.NewLoader()
-	doc, err := loader.LoadFromFile(location)
+	reader, err := newRefReader(location, l.RefRoot, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	loader := reader.loader()
+	doc, err := loader.LoadFromFile(reader.entry)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load from file")
 	}
@@ -32,3 +51,463 @@ func (l *OpenAPI3Loader) LoadFromFile(location string) (interface{}, error) {
 	}
 	return doc, nil
 }
+
+// ResolveFileImports returns the paths of all files referenced by the spec
+// at the given location, directly or transitively. The spec is not loaded.
+func (l *OpenAPI3Loader) ResolveFileImports(location string) ([]string, error) {
+	reader, err := newRefReader(location, l.RefRoot, nil)
+	if err != nil {
+		return nil, err
+	}
+	return reader.imports(openapiFields, openapiValues)
+}
+
+// refReader reads the files of a spec and the files it references
+type refReader struct {
+	// entry is the absolute path of the spec
+	entry string
+	// root is the absolute path of the directory references are restricted
+	// to, if set
+	root string
+	// transform rewrites the contents of the spec, or of the referenced file
+	// at the given absolute path if entry is false, before they are loaded
+	transform func(path string, data []byte, entry bool) ([]byte, error)
+	// refs are the absolute paths of all referenced files that were read
+	refs []string
+	seen map[string]bool
+}
+
+func newRefReader(location, root string, transform func(string, []byte, bool) ([]byte, error)) (*refReader, error) {
+	entry, err := filepath.Abs(location)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve absolute path to spec")
+	}
+
+	if len(root) != 0 {
+		root, err = filepath.Abs(root)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to resolve absolute path to reference root")
+		}
+	}
+
+	return &refReader{
+		entry:     entry,
+		root:      root,
+		transform: transform,
+		refs:      make([]string, 0),
+		seen:      map[string]bool{},
+	}, nil
+}
+
+// loader creates an openapi3 loader reading all files through the reader
+func (r *refReader) loader() *openapi3.Loader {
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+	loader.ReadFromURIFunc = func(_ *openapi3.Loader, location *url.URL) ([]byte, error) {
+		return r.read(location)
+	}
+	return loader
+}
+
+func (r *refReader) read(location *url.URL) ([]byte, error) {
+	if location.Scheme != "" || location.Host != "" || location.RawQuery != "" {
+		return nil, fmt.Errorf("unsupported reference %s, only local files can be referenced", location)
+	}
+
+	path, err := filepath.Abs(filepath.FromSlash(location.Path))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve absolute path to referenced file")
+	}
+	entry := path == r.entry
+
+	if !entry && len(r.root) != 0 {
+		rel, err := filepath.Rel(r.root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("reference to %s is outside of %s", path, r.root)
+		}
+	}
+
+	byt, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !entry && !r.seen[path] {
+		r.seen[path] = true
+		r.refs = append(r.refs, path)
+	}
+
+	if r.transform != nil {
+		return r.transform(path, byt, entry)
+	}
+	return byt, nil
+}
+
+// imports returns the absolute paths of all files referenced by the spec,
+// directly or transitively, by walking the fields of its objects holding
+// references. Neither the spec nor the referenced files are loaded.
+func (r *refReader) imports(fields map[openapiKind]map[string]openapiField, values map[openapiKind]openapiKind) ([]string, error) {
+	type target struct {
+		file    string
+		pointer string
+		kind    openapiKind
+	}
+
+	nodes := map[string]interface{}{}
+	targets := []target{{file: r.entry, kind: openapiDocument}}
+	walked := map[target]bool{}
+	for len(targets) != 0 {
+		t := targets[0]
+		targets = targets[1:]
+		if walked[t] {
+			continue
+		}
+		walked[t] = true
+
+		node, ok := nodes[t.file]
+		if !ok {
+			var err error
+			node, err = r.decode(t.file)
+			if err != nil {
+				return nil, err
+			}
+			nodes[t.file] = node
+		}
+
+		value, ok := lookupPointer(node, t.pointer)
+		if !ok {
+			continue
+		}
+
+		walker := &openapiWalker{
+			fields: fields,
+			values: values,
+			ref: func(_ map[string]interface{}, ref string, kind openapiKind) error {
+				file, pointer, ok := resolveLocalRef(t.file, ref)
+				if !ok {
+					return fmt.Errorf("unsupported reference %s, only local files can be referenced", ref)
+				}
+				targets = append(targets, target{file: file, pointer: pointer, kind: kind})
+				return nil
+			},
+		}
+		err := walker.walk(value, t.kind, t.pointer)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r.refs, nil
+}
+
+// decode reads and decodes the file at the given absolute path
+func (r *refReader) decode(path string) (interface{}, error) {
+	byt, err := r.read(&url.URL{Path: filepath.ToSlash(path)})
+	if err != nil {
+		return nil, err
+	}
+
+	var node interface{}
+	err = yaml.Unmarshal(byt, &node)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s", path)
+	}
+	return node, nil
+}
+
+// openapiKind is the kind of object at a position of an OpenAPI document
+type openapiKind int
+
+const (
+	openapiDocument openapiKind = iota
+	openapiComponents
+	openapiPaths
+	openapiPathItem
+	openapiOperation
+	openapiCallback
+	openapiParameter
+	openapiRequestBody
+	openapiResponses
+	openapiResponse
+	openapiContent
+	openapiMediaType
+	openapiEncoding
+	openapiSchema
+	// openapiOther are objects that are never walked, like examples
+	openapiOther
+)
+
+// openapiField is a field holding objects of a kind
+type openapiField struct {
+	kind openapiKind
+	// shape is how the objects are held by the field
+	shape openapiShape
+}
+
+type openapiShape int
+
+const (
+	// openapiOne fields hold a single object
+	openapiOne openapiShape = iota
+	// openapiMap fields hold a map from names to objects
+	openapiMap
+	// openapiList fields hold a list of objects
+	openapiList
+	// openapiOneOrList fields hold a single object or a list of objects
+	openapiOneOrList
+)
+
+// openapiFields are the fields of each kind of object that hold other
+// objects. Fields holding examples and other values are left out.
+var openapiFields = map[openapiKind]map[string]openapiField{
+	openapiDocument: {
+		"paths":      {openapiPaths, openapiOne},
+		"components": {openapiComponents, openapiOne},
+	},
+	openapiComponents: {
+		"schemas":         {openapiSchema, openapiMap},
+		"responses":       {openapiResponse, openapiMap},
+		"parameters":      {openapiParameter, openapiMap},
+		"examples":        {openapiOther, openapiMap},
+		"requestBodies":   {openapiRequestBody, openapiMap},
+		"headers":         {openapiParameter, openapiMap},
+		"securitySchemes": {openapiOther, openapiMap},
+		"links":           {openapiOther, openapiMap},
+		"callbacks":       {openapiCallback, openapiMap},
+		"pathItems":       {openapiPathItem, openapiMap},
+	},
+	openapiPathItem: {
+		"parameters": {openapiParameter, openapiList},
+		"get":        {openapiOperation, openapiOne},
+		"put":        {openapiOperation, openapiOne},
+		"post":       {openapiOperation, openapiOne},
+		"delete":     {openapiOperation, openapiOne},
+		"options":    {openapiOperation, openapiOne},
+		"head":       {openapiOperation, openapiOne},
+		"patch":      {openapiOperation, openapiOne},
+		"trace":      {openapiOperation, openapiOne},
+	},
+	openapiOperation: {
+		"parameters":  {openapiParameter, openapiList},
+		"requestBody": {openapiRequestBody, openapiOne},
+		"responses":   {openapiResponses, openapiOne},
+		"callbacks":   {openapiCallback, openapiMap},
+	},
+	// parameters and headers share their fields
+	openapiParameter: {
+		"schema":   {openapiSchema, openapiOne},
+		"content":  {openapiContent, openapiOne},
+		"examples": {openapiOther, openapiMap},
+	},
+	openapiRequestBody: {
+		"content": {openapiContent, openapiOne},
+	},
+	openapiResponse: {
+		"headers": {openapiParameter, openapiMap},
+		"content": {openapiContent, openapiOne},
+		"links":   {openapiOther, openapiMap},
+	},
+	openapiMediaType: {
+		"schema":   {openapiSchema, openapiOne},
+		"examples": {openapiOther, openapiMap},
+		"encoding": {openapiEncoding, openapiMap},
+	},
+	openapiEncoding: {
+		"headers": {openapiParameter, openapiMap},
+	},
+	openapiSchema: {
+		"properties":            {openapiSchema, openapiMap},
+		"patternProperties":     {openapiSchema, openapiMap},
+		"dependentSchemas":      {openapiSchema, openapiMap},
+		"definitions":           {openapiSchema, openapiMap},
+		"$defs":                 {openapiSchema, openapiMap},
+		"items":                 {openapiSchema, openapiOne},
+		"additionalItems":       {openapiSchema, openapiOne},
+		"additionalProperties":  {openapiSchema, openapiOne},
+		"unevaluatedItems":      {openapiSchema, openapiOne},
+		"unevaluatedProperties": {openapiSchema, openapiOne},
+		"contains":              {openapiSchema, openapiOne},
+		"propertyNames":         {openapiSchema, openapiOne},
+		"contentSchema":         {openapiSchema, openapiOne},
+		"not":                   {openapiSchema, openapiOne},
+		"if":                    {openapiSchema, openapiOne},
+		"then":                  {openapiSchema, openapiOne},
+		"else":                  {openapiSchema, openapiOne},
+		"allOf":                 {openapiSchema, openapiList},
+		"anyOf":                 {openapiSchema, openapiList},
+		"oneOf":                 {openapiSchema, openapiList},
+		"prefixItems":           {openapiSchema, openapiList},
+	},
+}
+
+// openapiValues are the kinds of objects whose fields, other than
+// extensions, all hold objects of the same kind
+var openapiValues = map[openapiKind]openapiKind{
+	openapiPaths:     openapiPathItem,
+	openapiCallback:  openapiPathItem,
+	openapiResponses: openapiResponse,
+	openapiContent:   openapiMediaType,
+}
+
+// openapiWalker walks the objects of a decoded OpenAPI document by their
+// position, so schemas are told apart from examples and extensions that
+// merely look like them
+type openapiWalker struct {
+	// fields are the fields holding objects of each kind
+	fields map[openapiKind]map[string]openapiField
+	// values are the kinds of objects all of whose fields hold objects of
+	// the same kind
+	values map[openapiKind]openapiKind
+	// schema is called for every schema before its children are walked,
+	// along with its JSON pointer
+	schema func(schema map[string]interface{}, pointer string) error
+	// ref is called for every reference object along with the kind of
+	// object it refers to, and may rewrite the reference object in place
+	ref func(object map[string]interface{}, ref string, kind openapiKind) error
+}
+
+// walk walks the object of the given kind at the given JSON pointer and
+// all objects it holds
+func (w *openapiWalker) walk(node interface{}, kind openapiKind, pointer string) error {
+	object, ok := node.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	if ref, ok := object["$ref"].(string); ok {
+		if w.ref != nil {
+			err := w.ref(object, ref, kind)
+			if err != nil {
+				return err
+			}
+		}
+		// only schemas may have keywords next to references since 3.1
+		if kind != openapiSchema {
+			return nil
+		}
+	}
+
+	if kind == openapiSchema && w.schema != nil {
+		err := w.schema(object, pointer)
+		if err != nil {
+			return err
+		}
+	}
+
+	if values, ok := w.values[kind]; ok {
+		for _, key := range objectKeys(object) {
+			if strings.HasPrefix(key, "x-") {
+				continue
+			}
+			err := w.walk(object[key], values, pointer+"/"+escapePointer(key))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	fields := w.fields[kind]
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field := fields[key]
+		value, ok := object[key]
+		if !ok {
+			continue
+		}
+		pointer := pointer + "/" + escapePointer(key)
+
+		var err error
+		switch field.shape {
+		case openapiOne:
+			err = w.walk(value, field.kind, pointer)
+		case openapiMap:
+			values, _ := value.(map[string]interface{})
+			for _, name := range objectKeys(values) {
+				err = w.walk(values[name], field.kind, pointer+"/"+escapePointer(name))
+				if err != nil {
+					break
+				}
+			}
+		case openapiList, openapiOneOrList:
+			values, ok := value.([]interface{})
+			if !ok && field.shape == openapiOneOrList {
+				err = w.walk(value, field.kind, pointer)
+			}
+			for i, value := range values {
+				err = w.walk(value, field.kind, pointer+"/"+strconv.Itoa(i))
+				if err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// objectKeys returns the sorted keys of an object
+func objectKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// resolveLocalRef returns the absolute path of the file and the JSON pointer
+// referenced from the file at the given absolute path. Returns false for
+// references to remote files.
+func resolveLocalRef(path, ref string) (string, string, bool) {
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return "", "", false
+	}
+
+	if len(u.Path) != 0 {
+		file := filepath.FromSlash(u.Path)
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+		path = filepath.Clean(file)
+	}
+	return path, u.Fragment, true
+}
+
+// lookupPointer returns the value at the given JSON pointer of the node
+func lookupPointer(node interface{}, pointer string) (interface{}, bool) {
+	if len(pointer) == 0 {
+		return node, true
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, false
+	}
+
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = unescapePointer(token)
+		switch value := node.(type) {
+		case map[string]interface{}:
+			child, ok := value[token]
+			if !ok {
+				return nil, false
+			}
+			node = child
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(value) {
+				return nil, false
+			}
+			node = value[i]
+		default:
+			return nil, false
+		}
+	}
+	return node, true
+}
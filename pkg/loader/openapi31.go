@@ -0,0 +1,224 @@
+package loader
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// openapi30Version is the version OpenAPI 3.1 specifications are downgraded
+// to
+const openapi30Version = "3.0.3"
+
+// OpenAPI31Loader loads OpenAPI 3.1 specifications into the OpenAPI 3.0
+// model. Schema keywords introduced by 3.1 are rewritten to their 3.0
+// equivalents where one exists, webhooks are dropped.
+type OpenAPI31Loader struct {
+	// RefRoot is the directory external references are restricted to, if set
+	RefRoot string
+}
+
+func (l *OpenAPI31Loader) LoadFromData(data []byte) (interface{}, error) {
+	data, err := newOpenAPI31Normalizer().normalize("", data, true)
+	if err != nil {
+		return nil, err
+	}
+
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load from data")
+	}
+	err = doc.Validate(loader.Context)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to validate openapi v3.1 spec")
+	}
+	return doc, nil
+}
+
+// LoadFromFile loads the spec at the given location. External references
+// are resolved relative to the file containing them and are normalized as
+// well.
+func (l *OpenAPI31Loader) LoadFromFile(location string) (interface{}, error) {
+	reader, err := newRefReader(location, l.RefRoot, newOpenAPI31Normalizer().normalize)
+	if err != nil {
+		return nil, err
+	}
+
+	loader := reader.loader()
+	doc, err := loader.LoadFromFile(reader.entry)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load from file")
+	}
+	err = doc.Validate(loader.Context)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to validate openapi v3.1 spec")
+	}
+	return doc, nil
+}
+
+// ResolveFileImports returns the paths of all files referenced by the spec
+// at the given location, directly or transitively. The spec is not loaded.
+func (l *OpenAPI31Loader) ResolveFileImports(location string) ([]string, error) {
+	reader, err := newRefReader(location, l.RefRoot, nil)
+	if err != nil {
+		return nil, err
+	}
+	return reader.imports(openapiFields, openapiValues)
+}
+
+// openapi31Normalizer rewrites an OpenAPI 3.1 specification and the files
+// it references so they can be loaded as OpenAPI 3.0. Only schemas are
+// rewritten, so examples and other values resembling schemas are kept as
+// they are. The objects in referenced files are told apart by the
+// positions of the references pointing to them.
+type openapi31Normalizer struct {
+	// refs are the referenced objects in each file by their absolute path
+	refs map[string][]openapiTarget
+}
+
+// openapiTarget is an object referenced within a file
+type openapiTarget struct {
+	pointer string
+	kind    openapiKind
+}
+
+func newOpenAPI31Normalizer() *openapi31Normalizer {
+	return &openapi31Normalizer{
+		refs: map[string][]openapiTarget{},
+	}
+}
+
+// normalize rewrites the encoded spec, or the file referenced by it at the
+// given absolute path if entry is false
+func (n *openapi31Normalizer) normalize(path string, data []byte, entry bool) ([]byte, error) {
+	var node interface{}
+	err := yaml.Unmarshal(data, &node)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load from data")
+	}
+
+	targets := append([]openapiTarget{}, n.refs[path]...)
+	if doc, ok := node.(map[string]interface{}); ok && entry {
+		normalizeOpenAPI31(doc)
+		targets = append([]openapiTarget{{kind: openapiDocument}}, targets...)
+	}
+
+	walker := &openapiWalker{
+		fields: openapiFields,
+		values: openapiValues,
+		schema: func(schema map[string]interface{}, pointer string) error {
+			return normalizeSchema(schema, path+"#"+pointer)
+		},
+		ref: func(_ map[string]interface{}, ref string, kind openapiKind) error {
+			file, pointer, ok := resolveLocalRef(path, ref)
+			if !ok {
+				return nil
+			}
+			target := openapiTarget{pointer: pointer, kind: kind}
+			if file == path {
+				targets = append(targets, target)
+			} else {
+				n.refs[file] = append(n.refs[file], target)
+			}
+			return nil
+		},
+	}
+
+	// references within the file are walked as well, once per kind
+	walked := map[openapiTarget]bool{}
+	for len(targets) != 0 {
+		target := targets[0]
+		targets = targets[1:]
+		if walked[target] {
+			continue
+		}
+		walked[target] = true
+
+		value, ok := lookupPointer(node, target.pointer)
+		if !ok {
+			continue
+		}
+		err = walker.walk(value, target.kind, target.pointer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data, err = json.Marshal(node)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to normalize openapi v3.1 spec")
+	}
+	return data, nil
+}
+
+// normalizeOpenAPI31 rewrites the top level fields of a decoded OpenAPI 3.1
+// specification so it can be loaded as OpenAPI 3.0
+func normalizeOpenAPI31(spec map[string]interface{}) {
+	spec["openapi"] = openapi30Version
+	delete(spec, "webhooks")
+	delete(spec, "jsonSchemaDialect")
+
+	// paths are optional since 3.1
+	if _, ok := spec["paths"]; !ok {
+		spec["paths"] = map[string]interface{}{}
+	}
+
+	if info, ok := spec["info"].(map[string]interface{}); ok {
+		if license, ok := info["license"].(map[string]interface{}); ok {
+			delete(license, "identifier")
+		}
+		delete(info, "summary")
+	}
+}
+
+// normalizeSchema rewrites the 3.1 keywords of a single schema object at the
+// given location
+func normalizeSchema(schema map[string]interface{}, location string) error {
+	// type arrays including null become nullable types
+	if types, ok := schema["type"].([]interface{}); ok {
+		nonNull := make([]interface{}, 0, len(types))
+		for _, typ := range types {
+			if typ == "null" {
+				schema["nullable"] = true
+			} else {
+				nonNull = append(nonNull, typ)
+			}
+		}
+		if len(nonNull) > 1 {
+			return fmt.Errorf("schema %s allows the types %v, which cannot be represented in openapi v3.0", location, nonNull)
+		}
+
+		delete(schema, "type")
+		if len(nonNull) == 1 {
+			schema["type"] = nonNull[0]
+		}
+	} else if schema["type"] == "null" {
+		delete(schema, "type")
+		schema["nullable"] = true
+	}
+
+	// exclusive bounds are numbers instead of flags since 3.1
+	for bound, flag := range map[string]string{"minimum": "exclusiveMinimum", "maximum": "exclusiveMaximum"} {
+		if value, ok := schema[flag].(float64); ok {
+			schema[bound] = value
+			schema[flag] = true
+		}
+	}
+
+	if value, ok := schema["const"]; ok {
+		delete(schema, "const")
+		schema["enum"] = []interface{}{value}
+	}
+
+	if examples, ok := schema["examples"].([]interface{}); ok {
+		delete(schema, "examples")
+		if len(examples) != 0 {
+			schema["example"] = examples[0]
+		}
+	}
+	return nil
+}
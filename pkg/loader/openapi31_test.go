@@ -0,0 +1,169 @@
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+)
+
+const openapi31Header = `openapi: 3.1.0
+info:
+  title: Pets
+  version: 1.0.0
+`
+
+func Test_OpenAPI31Loader(t *testing.T) {
+	type want struct {
+		err        bool
+		errMessage string
+		check      func(t *testing.T, doc *openapi3.T)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  want
+	}{
+		{
+			name: "normalizes schemas",
+			input: openapi31Header + `
+components:
+  schemas:
+    Pet:
+      type: object
+      properties:
+        age:
+          type: [integer, "null"]
+          exclusiveMinimum: 0
+        kind:
+          const: dog
+`,
+			want: want{
+				check: func(t *testing.T, doc *openapi3.T) {
+					pet := doc.Components.Schemas["Pet"].Value
+					age := pet.Properties["age"].Value
+					assert.Equal(t, "integer", age.Type)
+					assert.True(t, age.Nullable)
+					assert.True(t, age.ExclusiveMin)
+					assert.Equal(t, []interface{}{"dog"}, pet.Properties["kind"].Value.Enum)
+				},
+			},
+		},
+		{
+			name: "keeps examples resembling schemas",
+			input: openapi31Header + `
+paths:
+  /pets:
+    get:
+      responses:
+        "200":
+          description: A pet
+          content:
+            application/json:
+              schema:
+                type: object
+                default:
+                  type: [a, b]
+              example:
+                type: [cat, "null"]
+                const: 1
+components:
+  schemas:
+    Pet:
+      type: object
+      x-meta:
+        type: "null"
+`,
+			want: want{
+				check: func(t *testing.T, doc *openapi3.T) {
+					media := doc.Paths["/pets"].Get.Responses["200"].Value.Content["application/json"]
+					assert.Equal(t, map[string]interface{}{"type": []interface{}{"cat", "null"}, "const": float64(1)}, media.Example)
+					assert.Equal(t, map[string]interface{}{"type": []interface{}{"a", "b"}}, media.Schema.Value.Default)
+				},
+			},
+		},
+		{
+			name: "rejects schemas with several types",
+			input: openapi31Header + `
+components:
+  schemas:
+    ID:
+      type: [string, integer]
+`,
+			want: want{
+				err:        true,
+				errMessage: "allows the types [string integer]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := (&OpenAPI31Loader{}).LoadFromData([]byte(tt.input))
+			if tt.want.err {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.want.errMessage)
+				return
+			}
+			assert.NoError(t, err)
+			tt.want.check(t, doc.(*openapi3.T))
+		})
+	}
+}
+
+func Test_OpenAPI31Loader_Refs(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"openapi.yml": openapi31Header + `
+paths:
+  /pets:
+    get:
+      parameters:
+        - $ref: ./parameters.yml#/Limit
+      responses:
+        "200":
+          description: A pet
+          content:
+            application/json:
+              schema:
+                $ref: ./pet.yml#/Pet
+`,
+		"parameters.yml": `
+Limit:
+  name: limit
+  in: query
+  schema:
+    type: [integer, "null"]
+  example:
+    type: [a, b]
+`,
+		"pet.yml": `
+Pet:
+  type: object
+  properties:
+    tag:
+      $ref: "#/Tag"
+Tag:
+  type: [string, "null"]
+`,
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		assert.NoError(t, err)
+	}
+
+	doc, err := (&OpenAPI31Loader{}).LoadFromFile(filepath.Join(dir, "openapi.yml"))
+	assert.NoError(t, err)
+
+	op := doc.(*openapi3.T).Paths["/pets"].Get
+	limit := op.Parameters[0].Value
+	assert.Equal(t, "integer", limit.Schema.Value.Type)
+	assert.Equal(t, map[string]interface{}{"type": []interface{}{"a", "b"}}, limit.Example)
+
+	tag := op.Responses["200"].Value.Content["application/json"].Schema.Value.Properties["tag"].Value
+	assert.Equal(t, "string", tag.Type)
+	assert.True(t, tag.Nullable)
+}
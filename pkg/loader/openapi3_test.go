@@ -0,0 +1,106 @@
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ResolveFileImports(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		// specs are not validated, so the missing info object is ignored
+		"openapi.yml": `
+openapi: 3.0.3
+paths:
+  /pets:
+    get:
+      responses:
+        "200":
+          description: A pet
+          content:
+            application/json:
+              schema:
+                $ref: ./pet.yml#/Pet
+              example:
+                $ref: ./missing.yml
+`,
+		"openapi31.yml": `
+openapi: 3.1.0
+components:
+  schemas:
+    Pet:
+      $ref: ./pet.yml#/Pet
+      examples:
+        - $ref: ./missing.yml
+`,
+		"pet.yml": `
+Pet:
+  type: object
+  properties:
+    tag:
+      $ref: ./tag.yml
+Unused:
+  $ref: ./missing.yml
+`,
+		"tag.yml": `
+type: string
+`,
+		"schema.json": `{
+  "type": "array",
+  "items": [{"$ref": "./pet.json"}],
+  "default": {"$ref": "./missing.json"}
+}`,
+		"pet.json": `{
+  "properties": {"tag": {"$ref": "tag.json#/definitions/Tag"}}
+}`,
+		"tag.json": `{
+  "definitions": {"Tag": {"type": "string"}}
+}`,
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		assert.NoError(t, err)
+	}
+
+	tests := []struct {
+		name   string
+		loader FileImportResolver
+		input  string
+		want   []string
+	}{
+		{
+			name:   "openapi3",
+			loader: &OpenAPI3Loader{},
+			input:  "openapi.yml",
+			want:   []string{"pet.yml", "tag.yml"},
+		},
+		{
+			name:   "openapi31",
+			loader: &OpenAPI31Loader{},
+			input:  "openapi31.yml",
+			want:   []string{"pet.yml", "tag.yml"},
+		},
+		{
+			name:   "jsonschema",
+			loader: &JSONSchemaLoader{},
+			input:  "schema.json",
+			want:   []string{"pet.json", "tag.json"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refs, err := tt.loader.ResolveFileImports(filepath.Join(dir, tt.input))
+			assert.NoError(t, err)
+
+			want := make([]string, 0, len(tt.want))
+			for _, file := range tt.want {
+				want = append(want, filepath.Join(dir, file))
+			}
+			assert.ElementsMatch(t, want, refs)
+		})
+	}
+}
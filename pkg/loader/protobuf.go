@@ -0,0 +1,584 @@
+package loader
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/proto"
+	"github.com/pkg/errors"
+)
+
+// ProtobufLoader loads .proto files along with all files they import. Imports
+// are resolved by searching the import paths in order.
+type ProtobufLoader struct {
+	// ImportPaths are the directories searched for imported files
+	ImportPaths []string
+}
+
+// ProtoSchema is the template model of a .proto file. The fields of the
+// loaded file are promoted, so templates can range over `.Messages` directly.
+type ProtoSchema struct {
+	*ProtoFile
+	// Imports are all files imported by the loaded file, directly or
+	// transitively, in the order they were loaded
+	Imports []*ProtoFile
+
+	messages map[string]*ProtoMessage
+	enums    map[string]*ProtoEnum
+}
+
+// ProtoFile is a parsed .proto file
+type ProtoFile struct {
+	// Name is the import path of the file
+	Name     string
+	Syntax   string
+	Package  string
+	Comment  string
+	Imports  []string
+	Options  []*ProtoOption
+	Messages []*ProtoMessage
+	Enums    []*ProtoEnum
+	Services []*ProtoService
+}
+
+// ProtoMessage is a message definition, including its nested definitions
+type ProtoMessage struct {
+	Name string
+	// FullName is the fully qualified name including the package
+	FullName string
+	Comment  string
+	Fields   []*ProtoField
+	Oneofs   []*ProtoOneof
+	Messages []*ProtoMessage
+	Enums    []*ProtoEnum
+	Options  []*ProtoOption
+}
+
+// ProtoField is a field of a message
+type ProtoField struct {
+	Name string
+	// Number is the field number
+	Number int
+	// Type is the type as written in the .proto file
+	Type string
+	// FullType is the fully qualified name of message and enum types. It is
+	// equal to Type for scalar types and types that could not be resolved.
+	FullType string
+	Repeated bool
+	Optional bool
+	Required bool
+	// Map is set for map fields, Type and FullType then describe the value
+	Map     bool
+	KeyType string
+	// Oneof is the name of the oneof the field belongs to, if any
+	Oneof   string
+	Comment string
+	Options []*ProtoOption
+}
+
+// ProtoOneof is a oneof definition of a message
+type ProtoOneof struct {
+	Name    string
+	Comment string
+	Fields  []*ProtoField
+	Options []*ProtoOption
+}
+
+// ProtoEnum is an enum definition
+type ProtoEnum struct {
+	Name string
+	// FullName is the fully qualified name including the package
+	FullName string
+	Comment  string
+	Values   []*ProtoEnumValue
+	Options  []*ProtoOption
+}
+
+// ProtoEnumValue is a value of an enum
+type ProtoEnumValue struct {
+	Name    string
+	Number  int
+	Comment string
+	Options []*ProtoOption
+}
+
+// ProtoService is a service definition
+type ProtoService struct {
+	Name string
+	// FullName is the fully qualified name including the package
+	FullName string
+	Comment  string
+	RPCs     []*ProtoRPC
+	Options  []*ProtoOption
+}
+
+// ProtoRPC is a method of a service
+type ProtoRPC struct {
+	Name            string
+	Comment         string
+	RequestType     string
+	ResponseType    string
+	ClientStreaming bool
+	ServerStreaming bool
+	// RequestFullType and ResponseFullType are the fully qualified names of
+	// the request and response messages
+	RequestFullType  string
+	ResponseFullType string
+	Options          []*ProtoOption
+}
+
+// ProtoOption is an option set on a definition. Values of string options are
+// unquoted, aggregate values are formatted in text format.
+type ProtoOption struct {
+	Name  string
+	Value string
+}
+
+var protoScalars = map[string]bool{
+	"double": true, "float": true, "bool": true, "string": true, "bytes": true,
+	"int32": true, "int64": true, "uint32": true, "uint64": true,
+	"sint32": true, "sint64": true, "fixed32": true, "fixed64": true,
+	"sfixed32": true, "sfixed64": true,
+}
+
+func (l *ProtobufLoader) LoadFromData(data []byte) (interface{}, error) {
+	file, err := parseProto("", data)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := &ProtoSchema{
+		ProtoFile: file,
+		Imports:   make([]*ProtoFile, 0),
+	}
+
+	loaded := map[string]bool{}
+	queue := append([]string{}, file.Imports...)
+	for len(queue) != 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if loaded[name] {
+			continue
+		}
+		loaded[name] = true
+
+		path, ok := l.resolveImport(name)
+		if !ok {
+			// well-known types ship with protoc and are rarely available
+			// on disk, so they are allowed to be missing
+			if strings.HasPrefix(name, "google/protobuf/") {
+				continue
+			}
+			return nil, errors.Errorf("failed to resolve import %s", name)
+		}
+
+		byt, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read import %s", name)
+		}
+
+		imported, err := parseProto(name, byt)
+		if err != nil {
+			return nil, err
+		}
+
+		schema.Imports = append(schema.Imports, imported)
+		queue = append(queue, imported.Imports...)
+	}
+
+	schema.resolveTypes()
+	return schema, nil
+}
+
+func (l *ProtobufLoader) LoadFromFile(location string) (interface{}, error) {
+	byt, err := os.ReadFile(location)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read proto file")
+	}
+
+	withDir := &ProtobufLoader{
+		ImportPaths: append([]string{filepath.Dir(location)}, l.ImportPaths...),
+	}
+	return withDir.LoadFromData(byt)
+}
+
+// ResolveImports returns the paths of all files imported by the input,
+// directly or transitively, that exist in the import paths
+func (l *ProtobufLoader) ResolveImports(data []byte) ([]string, error) {
+	schema, err := l.LoadFromData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0)
+	for _, file := range schema.(*ProtoSchema).Imports {
+		if path, ok := l.resolveImport(file.Name); ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// resolveImport searches the import paths for the imported file
+func (l *ProtobufLoader) resolveImport(name string) (string, bool) {
+	for _, dir := range l.ImportPaths {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return path, true
+			}
+			return abs, true
+		}
+	}
+	return "", false
+}
+
+// Message returns the message with the given fully qualified name from the
+// loaded file or any of its imports
+func (s *ProtoSchema) Message(fullName string) *ProtoMessage {
+	return s.messages[strings.TrimPrefix(fullName, ".")]
+}
+
+// Enum returns the enum with the given fully qualified name from the loaded
+// file or any of its imports
+func (s *ProtoSchema) Enum(fullName string) *ProtoEnum {
+	return s.enums[strings.TrimPrefix(fullName, ".")]
+}
+
+// resolveTypes indexes all definitions and resolves the full names of field,
+// request and response types using the protobuf scoping rules
+func (s *ProtoSchema) resolveTypes() {
+	s.messages = map[string]*ProtoMessage{}
+	s.enums = map[string]*ProtoEnum{}
+
+	files := append([]*ProtoFile{s.ProtoFile}, s.Imports...)
+	for _, file := range files {
+		s.index(file.Messages, file.Enums)
+	}
+
+	for _, file := range files {
+		for _, msg := range file.Messages {
+			s.resolveMessage(msg)
+		}
+
+		for _, svc := range file.Services {
+			scope := file.Package
+			for _, rpc := range svc.RPCs {
+				rpc.RequestFullType = s.resolve(scope, rpc.RequestType)
+				rpc.ResponseFullType = s.resolve(scope, rpc.ResponseType)
+			}
+		}
+	}
+}
+
+func (s *ProtoSchema) index(messages []*ProtoMessage, enums []*ProtoEnum) {
+	for _, enum := range enums {
+		s.enums[enum.FullName] = enum
+	}
+	for _, msg := range messages {
+		s.messages[msg.FullName] = msg
+		s.index(msg.Messages, msg.Enums)
+	}
+}
+
+func (s *ProtoSchema) resolveMessage(msg *ProtoMessage) {
+	for _, field := range msg.Fields {
+		field.FullType = s.resolve(msg.FullName, field.Type)
+	}
+	for _, nested := range msg.Messages {
+		s.resolveMessage(nested)
+	}
+}
+
+// resolve looks up a type name in the given scope and all of its parent
+// scopes. Returns the name unchanged if it is a scalar or cannot be found.
+func (s *ProtoSchema) resolve(scope, name string) string {
+	if protoScalars[name] {
+		return name
+	}
+
+	if strings.HasPrefix(name, ".") {
+		return strings.TrimPrefix(name, ".")
+	}
+
+	for {
+		candidate := name
+		if len(scope) != 0 {
+			candidate = scope + "." + name
+		}
+
+		if s.messages[candidate] != nil || s.enums[candidate] != nil {
+			return candidate
+		}
+
+		if len(scope) == 0 {
+			return name
+		}
+
+		if i := strings.LastIndex(scope, "."); i != -1 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// parseProto parses the .proto file into its template model
+func parseProto(name string, data []byte) (*ProtoFile, error) {
+	parser := proto.NewParser(bytes.NewReader(data))
+	if len(name) != 0 {
+		parser.Filename(name)
+	}
+
+	def, err := parser.Parse()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse proto file")
+	}
+
+	file := &ProtoFile{
+		Name:     name,
+		Imports:  make([]string, 0),
+		Options:  make([]*ProtoOption, 0),
+		Messages: make([]*ProtoMessage, 0),
+		Enums:    make([]*ProtoEnum, 0),
+		Services: make([]*ProtoService, 0),
+	}
+
+	// the package is needed to qualify names, so it is read first
+	for _, elem := range def.Elements {
+		if pkg, ok := elem.(*proto.Package); ok {
+			file.Package = pkg.Name
+		}
+	}
+
+	for _, elem := range def.Elements {
+		switch e := elem.(type) {
+		case *proto.Syntax:
+			file.Syntax = e.Value
+			file.Comment = protoComment(e.Comment, nil)
+		case *proto.Import:
+			file.Imports = append(file.Imports, e.Filename)
+		case *proto.Option:
+			file.Options = append(file.Options, protoOption(e))
+		case *proto.Message:
+			if !e.IsExtend {
+				file.Messages = append(file.Messages, protoMessage(file.Package, e))
+			}
+		case *proto.Enum:
+			file.Enums = append(file.Enums, protoEnum(file.Package, e))
+		case *proto.Service:
+			file.Services = append(file.Services, protoService(file.Package, e))
+		}
+	}
+
+	return file, nil
+}
+
+func protoMessage(scope string, m *proto.Message) *ProtoMessage {
+	msg := &ProtoMessage{
+		Name:     m.Name,
+		FullName: qualify(scope, m.Name),
+		Comment:  protoComment(m.Comment, nil),
+		Fields:   make([]*ProtoField, 0),
+		Oneofs:   make([]*ProtoOneof, 0),
+		Messages: make([]*ProtoMessage, 0),
+		Enums:    make([]*ProtoEnum, 0),
+		Options:  make([]*ProtoOption, 0),
+	}
+
+	for _, elem := range m.Elements {
+		switch e := elem.(type) {
+		case *proto.NormalField:
+			field := protoField(e.Field)
+			field.Repeated = e.Repeated
+			field.Optional = e.Optional
+			field.Required = e.Required
+			msg.Fields = append(msg.Fields, field)
+		case *proto.MapField:
+			field := protoField(e.Field)
+			field.Map = true
+			field.KeyType = e.KeyType
+			msg.Fields = append(msg.Fields, field)
+		case *proto.Oneof:
+			oneof := &ProtoOneof{
+				Name:    e.Name,
+				Comment: protoComment(e.Comment, nil),
+				Fields:  make([]*ProtoField, 0),
+				Options: make([]*ProtoOption, 0),
+			}
+			for _, oneofElem := range e.Elements {
+				switch oe := oneofElem.(type) {
+				case *proto.OneOfField:
+					field := protoField(oe.Field)
+					field.Oneof = e.Name
+					oneof.Fields = append(oneof.Fields, field)
+					msg.Fields = append(msg.Fields, field)
+				case *proto.Option:
+					oneof.Options = append(oneof.Options, protoOption(oe))
+				}
+			}
+			msg.Oneofs = append(msg.Oneofs, oneof)
+		case *proto.Message:
+			if !e.IsExtend {
+				msg.Messages = append(msg.Messages, protoMessage(msg.FullName, e))
+			}
+		case *proto.Enum:
+			msg.Enums = append(msg.Enums, protoEnum(msg.FullName, e))
+		case *proto.Option:
+			msg.Options = append(msg.Options, protoOption(e))
+		}
+	}
+
+	return msg
+}
+
+func protoField(f *proto.Field) *ProtoField {
+	field := &ProtoField{
+		Name:     f.Name,
+		Number:   f.Sequence,
+		Type:     f.Type,
+		FullType: f.Type,
+		Comment:  protoComment(f.Comment, f.InlineComment),
+		Options:  make([]*ProtoOption, 0, len(f.Options)),
+	}
+
+	for _, opt := range f.Options {
+		field.Options = append(field.Options, protoOption(opt))
+	}
+
+	return field
+}
+
+func protoEnum(scope string, e *proto.Enum) *ProtoEnum {
+	enum := &ProtoEnum{
+		Name:     e.Name,
+		FullName: qualify(scope, e.Name),
+		Comment:  protoComment(e.Comment, nil),
+		Values:   make([]*ProtoEnumValue, 0),
+		Options:  make([]*ProtoOption, 0),
+	}
+
+	for _, elem := range e.Elements {
+		switch v := elem.(type) {
+		case *proto.EnumField:
+			value := &ProtoEnumValue{
+				Name:    v.Name,
+				Number:  v.Integer,
+				Comment: protoComment(v.Comment, v.InlineComment),
+				Options: make([]*ProtoOption, 0),
+			}
+			for _, valueElem := range v.Elements {
+				if opt, ok := valueElem.(*proto.Option); ok {
+					value.Options = append(value.Options, protoOption(opt))
+				}
+			}
+			enum.Values = append(enum.Values, value)
+		case *proto.Option:
+			enum.Options = append(enum.Options, protoOption(v))
+		}
+	}
+
+	return enum
+}
+
+func protoService(scope string, s *proto.Service) *ProtoService {
+	svc := &ProtoService{
+		Name:     s.Name,
+		FullName: qualify(scope, s.Name),
+		Comment:  protoComment(s.Comment, nil),
+		RPCs:     make([]*ProtoRPC, 0),
+		Options:  make([]*ProtoOption, 0),
+	}
+
+	for _, elem := range s.Elements {
+		switch e := elem.(type) {
+		case *proto.RPC:
+			rpc := &ProtoRPC{
+				Name:             e.Name,
+				Comment:          protoComment(e.Comment, e.InlineComment),
+				RequestType:      e.RequestType,
+				ResponseType:     e.ReturnsType,
+				ClientStreaming:  e.StreamsRequest,
+				ServerStreaming:  e.StreamsReturns,
+				RequestFullType:  e.RequestType,
+				ResponseFullType: e.ReturnsType,
+				Options:          make([]*ProtoOption, 0),
+			}
+			for _, rpcElem := range e.Elements {
+				if opt, ok := rpcElem.(*proto.Option); ok {
+					rpc.Options = append(rpc.Options, protoOption(opt))
+				}
+			}
+			svc.RPCs = append(svc.RPCs, rpc)
+		case *proto.Option:
+			svc.Options = append(svc.Options, protoOption(e))
+		}
+	}
+
+	return svc
+}
+
+func protoOption(o *proto.Option) *ProtoOption {
+	return &ProtoOption{
+		Name:  o.Name,
+		Value: protoLiteral(&o.Constant),
+	}
+}
+
+// protoLiteral formats an option value. Strings are unquoted, arrays and
+// aggregates are formatted in text format.
+func protoLiteral(l *proto.Literal) string {
+	switch {
+	case len(l.OrderedMap) != 0:
+		parts := make([]string, 0, len(l.OrderedMap))
+		for _, named := range l.OrderedMap {
+			value := protoLiteral(named.Literal)
+			if named.Literal.IsString {
+				value = strconv.Quote(value)
+			}
+			parts = append(parts, named.Name+": "+value)
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case l.Array != nil:
+		parts := make([]string, 0, len(l.Array))
+		for _, elem := range l.Array {
+			value := protoLiteral(elem)
+			if elem.IsString {
+				value = strconv.Quote(value)
+			}
+			parts = append(parts, value)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	default:
+		return l.Source
+	}
+}
+
+// protoComment joins the lines of the leading comment, or the inline comment
+// if there is no leading comment
+func protoComment(leading, inline *proto.Comment) string {
+	c := leading
+	if c == nil {
+		c = inline
+	}
+	if c == nil {
+		return ""
+	}
+
+	lines := make([]string, 0, len(c.Lines))
+	for _, line := range c.Lines {
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func qualify(scope, name string) string {
+	if len(scope) == 0 {
+		return name
+	}
+	return scope + "." + name
+}
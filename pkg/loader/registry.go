@@ -0,0 +1,148 @@
+package loader
+
+import (
+	"sort"
+	"sync"
+)
+
+// Options holds the generator options loaders can be configured with
+type Options struct {
+	// ImportPaths are the directories searched for files imported by the
+	// input
+	ImportPaths []string
+	// Operations are the paths to documents holding GraphQL operations
+	Operations []string
+	// Dialect is the dialect of SQL inputs
+	Dialect string
+	// Delimiter separates the fields of CSV inputs
+	Delimiter string
+	// RefRoot is the directory files referenced by the input are
+	// restricted to, if set
+	RefRoot string
+}
+
+// Factory creates a loader configured with the given options
+type Factory func(opts Options) SchemaLoader
+
+// Registry maps loader types to the factories creating them. A Registry is
+// safe for concurrent use.
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]Factory
+}
+
+// NewRegistry creates a registry holding the built-in loaders
+func NewRegistry() *Registry {
+	r := &Registry{
+		factories: map[string]Factory{},
+	}
+	registerBuiltins(r)
+	return r
+}
+
+// Register adds a loader type to the registry, replacing any loader
+// registered with the same name
+func (r *Registry) Register(name string, factory Factory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.factories[name] = factory
+}
+
+// Lookup returns the factory of a loader type
+func (r *Registry) Lookup(name string) (Factory, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	factory, ok := r.factories[name]
+	return factory, ok
+}
+
+// Names returns the sorted names of all registered loader types
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultRegistry is the registry used by generators that are not
+// configured with a registry of their own
+var DefaultRegistry = NewRegistry()
+
+// Register adds a loader type to the default registry
+func Register(name string, factory Factory) {
+	DefaultRegistry.Register(name, factory)
+}
+
+// Lookup returns the factory of a loader type of the default registry
+func Lookup(name string) (Factory, bool) {
+	return DefaultRegistry.Lookup(name)
+}
+
+func registerBuiltins(r *Registry) {
+	yaml := func(opts Options) SchemaLoader {
+		return &YamlLoader{}
+	}
+	r.Register("yml", yaml)
+	r.Register("yaml", yaml)
+	r.Register("json", func(opts Options) SchemaLoader {
+		return &JsonLoader{}
+	})
+	r.Register("toml", func(opts Options) SchemaLoader {
+		return &TomlLoader{}
+	})
+	r.Register("csv", func(opts Options) SchemaLoader {
+		return &CSVLoader{
+			Delimiter: opts.Delimiter,
+		}
+	})
+	r.Register("dotenv", func(opts Options) SchemaLoader {
+		return &DotenvLoader{}
+	})
+	r.Register("graphql", func(opts Options) SchemaLoader {
+		return &GraphqlLoader{}
+	})
+	r.Register("graphql_schema", func(opts Options) SchemaLoader {
+		return &GraphqlSchemaLoader{
+			Operations: opts.Operations,
+		}
+	})
+	r.Register("openapi3", func(opts Options) SchemaLoader {
+		return &OpenAPI3Loader{
+			RefRoot: opts.RefRoot,
+		}
+	})
+	r.Register("openapi31", func(opts Options) SchemaLoader {
+		return &OpenAPI31Loader{
+			RefRoot: opts.RefRoot,
+		}
+	})
+	r.Register("swagger2", func(opts Options) SchemaLoader {
+		return &Swagger2Loader{
+			RefRoot: opts.RefRoot,
+		}
+	})
+	r.Register("jsonschema", func(opts Options) SchemaLoader {
+		return &JSONSchemaLoader{
+			RefRoot: opts.RefRoot,
+		}
+	})
+	r.Register("sql", func(opts Options) SchemaLoader {
+		return &SQLLoader{
+			Dialect: opts.Dialect,
+		}
+	})
+	r.Register("go", func(opts Options) SchemaLoader {
+		return &GoLoader{}
+	})
+	protobuf := func(opts Options) SchemaLoader {
+		return &ProtobufLoader{
+			ImportPaths: opts.ImportPaths,
+		}
+	}
+	r.Register("proto", protobuf)
+	r.Register("protobuf", protobuf)
+}
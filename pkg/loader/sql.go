@@ -0,0 +1,1598 @@
+package loader
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// PostgresDialect is the default SQL dialect
+	PostgresDialect = "postgres"
+	SQLiteDialect   = "sqlite"
+)
+
+// SQLLoader parses SQL DDL statements into a model of the resulting tables,
+// enums and indexes. Statements are applied in order, so migrations altering
+// previously created tables are supported. Statements other than CREATE
+// TABLE, CREATE INDEX, CREATE TYPE ... AS ENUM, ALTER TABLE and DROP are
+// ignored.
+type SQLLoader struct {
+	// Dialect is the SQL dialect of the input, defaults to postgres
+	Dialect string
+}
+
+// SQLSchema is the model of all tables, enums and indexes defined by SQL
+// statements
+type SQLSchema struct {
+	Dialect string
+	Tables  []*SQLTable
+	Enums   []*SQLEnum
+	Indexes []*SQLIndex
+}
+
+// SQLTable is a table and its constraints
+type SQLTable struct {
+	// Schema is the schema qualifying the table name, if any
+	Schema      string
+	Name        string
+	Columns     []*SQLColumn
+	PrimaryKey  []string
+	ForeignKeys []*SQLForeignKey
+	Uniques     []*SQLUnique
+	Indexes     []*SQLIndex
+
+	primaryKeyName string
+}
+
+// SQLColumn is a column of a table
+type SQLColumn struct {
+	Name string
+	// Type is the type as written, lowercased and including arguments
+	Type string
+	// BaseType is the type without arguments or array brackets
+	BaseType string
+	// Array is set for array types
+	Array    bool
+	Nullable bool
+	// Default is the default value expression as written, if any
+	Default    string
+	HasDefault bool
+	PrimaryKey bool
+	Unique     bool
+	// AutoIncrement is set for serial and identity columns in postgres and
+	// for autoincrement and rowid alias columns in sqlite
+	AutoIncrement bool
+	// Generated is set for columns computed from other columns
+	Generated bool
+	// Enum is the enum type of the column, if any
+	Enum *SQLEnum
+	// References is the foreign key of the column, if any
+	References *SQLForeignKey
+}
+
+// SQLForeignKey is a foreign key constraint
+type SQLForeignKey struct {
+	Name       string
+	Columns    []string
+	RefSchema  string
+	RefTable   string
+	RefColumns []string
+	OnDelete   string
+	OnUpdate   string
+}
+
+// SQLUnique is a unique constraint
+type SQLUnique struct {
+	Name    string
+	Columns []string
+}
+
+// SQLIndex is an index created on a table
+type SQLIndex struct {
+	Name   string
+	Schema string
+	Table  string
+	// Columns are the indexed column names, or the expressions as written
+	Columns []string
+	Unique  bool
+	// Where is the predicate of partial indexes, if any
+	Where string
+}
+
+// SQLEnum is an enum type
+type SQLEnum struct {
+	Schema string
+	Name   string
+	Values []string
+}
+
+// Table returns the table with the given name, optionally qualified with
+// its schema
+func (s *SQLSchema) Table(name string) *SQLTable {
+	for _, table := range s.Tables {
+		if matchesName(table.Schema, table.Name, name) {
+			return table
+		}
+	}
+	return nil
+}
+
+// Enum returns the enum type with the given name, optionally qualified with
+// its schema
+func (s *SQLSchema) Enum(name string) *SQLEnum {
+	for _, enum := range s.Enums {
+		if matchesName(enum.Schema, enum.Name, name) {
+			return enum
+		}
+	}
+	return nil
+}
+
+// Column returns the column with the given name
+func (t *SQLTable) Column(name string) *SQLColumn {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column
+		}
+	}
+	return nil
+}
+
+func matchesName(schema, name, query string) bool {
+	return query == name || (len(schema) != 0 && query == schema+"."+name)
+}
+
+func (l *SQLLoader) LoadFromData(data []byte) (interface{}, error) {
+	dialect := l.Dialect
+	if len(dialect) == 0 {
+		dialect = PostgresDialect
+	}
+	if dialect != PostgresDialect && dialect != SQLiteDialect {
+		return nil, fmt.Errorf("unsupported sql dialect %s", dialect)
+	}
+
+	src := string(data)
+	tokens, err := lexSQL(src, dialect)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse sql")
+	}
+
+	p := &sqlParser{
+		src:     src,
+		tokens:  tokens,
+		dialect: dialect,
+		schema: &SQLSchema{
+			Dialect: dialect,
+			Tables:  make([]*SQLTable, 0),
+			Enums:   make([]*SQLEnum, 0),
+			Indexes: make([]*SQLIndex, 0),
+		},
+	}
+
+	err = p.parse()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse sql")
+	}
+
+	p.resolve()
+	return p.schema, nil
+}
+
+type sqlTokenKind int
+
+const (
+	sqlEOF sqlTokenKind = iota
+	sqlIdent
+	sqlQuotedIdent
+	sqlString
+	sqlNumber
+	sqlPunct
+)
+
+type sqlToken struct {
+	kind sqlTokenKind
+	// text is the identifier, the unquoted string or the punctuation
+	text string
+	// start and end are the offsets of the token in the source
+	start, end int
+}
+
+// lexSQL splits the source into tokens, skipping whitespace and comments
+func lexSQL(src, dialect string) ([]sqlToken, error) {
+	tokens := make([]sqlToken, 0)
+
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		start := i
+
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+
+		case strings.HasPrefix(src[i:], "--"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line(src, start))
+			}
+			i += end + 4
+
+		case (c == 'e' || c == 'E') && dialect == PostgresDialect && strings.HasPrefix(src[i+1:], "'"):
+			text, end, err := lexEscapeString(src, i+1)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, sqlToken{kind: sqlString, text: text, start: start, end: end})
+			i = end
+
+		case c == '\'':
+			text, end, err := lexQuoted(src, i, '\'')
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, sqlToken{kind: sqlString, text: text, start: start, end: end})
+			i = end
+
+		case c == '"' || c == '`' || (c == '[' && dialect == SQLiteDialect):
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			text, end, err := lexQuoted(src, i, closing)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, sqlToken{kind: sqlQuotedIdent, text: text, start: start, end: end})
+			i = end
+
+		case c == '$' && dialect == PostgresDialect && dollarTag(src[i:]) != "":
+			tag := dollarTag(src[i:])
+			end := strings.Index(src[i+len(tag):], tag)
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated dollar quoted string", line(src, start))
+			}
+			i += len(tag) + end + len(tag)
+			tokens = append(tokens, sqlToken{kind: sqlString, text: src[start+len(tag) : i-len(tag)], start: start, end: i})
+
+		case c == '_' || unicode.IsLetter(rune(c)) || c >= 0x80:
+			for i < len(src) && (src[i] == '_' || src[i] == '$' || src[i] >= 0x80 ||
+				unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i]))) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{kind: sqlIdent, text: src[start:i], start: start, end: i})
+
+		case unicode.IsDigit(rune(c)) || (c == '.' && i+1 < len(src) && unicode.IsDigit(rune(src[i+1]))):
+			for i < len(src) && (unicode.IsDigit(rune(src[i])) || src[i] == '.' ||
+				src[i] == 'e' || src[i] == 'E' ||
+				((src[i] == '+' || src[i] == '-') && (src[i-1] == 'e' || src[i-1] == 'E'))) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{kind: sqlNumber, text: src[start:i], start: start, end: i})
+
+		case strings.HasPrefix(src[i:], "::"):
+			i += 2
+			tokens = append(tokens, sqlToken{kind: sqlPunct, text: "::", start: start, end: i})
+
+		default:
+			i++
+			tokens = append(tokens, sqlToken{kind: sqlPunct, text: src[start:i], start: start, end: i})
+		}
+	}
+
+	return append(tokens, sqlToken{kind: sqlEOF, start: len(src), end: len(src)}), nil
+}
+
+// lexQuoted reads a quoted string or identifier starting at offset i, where
+// doubled closing quotes are escapes. Returns the unquoted text and the
+// offset after the closing quote.
+func lexQuoted(src string, i int, closing byte) (string, int, error) {
+	sb := &strings.Builder{}
+	for j := i + 1; j < len(src); j++ {
+		if src[j] != closing {
+			sb.WriteByte(src[j])
+			continue
+		}
+		if j+1 < len(src) && src[j+1] == closing {
+			sb.WriteByte(closing)
+			j++
+			continue
+		}
+		return sb.String(), j + 1, nil
+	}
+	return "", 0, fmt.Errorf("line %d: unterminated quoted string", line(src, i))
+}
+
+// lexEscapeString reads a postgres escape string constant whose opening
+// quote is at offset i, where backslashes start C-style escapes. Returns the
+// unescaped text and the offset after the closing quote.
+func lexEscapeString(src string, i int) (string, int, error) {
+	sb := &strings.Builder{}
+	for j := i + 1; j < len(src); j++ {
+		switch {
+		case src[j] == '\'' && j+1 < len(src) && src[j+1] == '\'':
+			sb.WriteByte('\'')
+			j++
+		case src[j] == '\'':
+			return sb.String(), j + 1, nil
+		case src[j] == '\\' && j+1 < len(src):
+			j++
+			if r, n := escapeSequence(src[j:]); n != 0 {
+				sb.WriteRune(r)
+				j += n - 1
+			} else {
+				sb.WriteByte(src[j])
+			}
+		default:
+			sb.WriteByte(src[j])
+		}
+	}
+	return "", 0, fmt.Errorf("line %d: unterminated quoted string", line(src, i))
+}
+
+// escapeSequence decodes the escape sequence following a backslash in an
+// escape string. Returns the length of the sequence, or 0 for backslashes
+// escaping the following character.
+func escapeSequence(src string) (rune, int) {
+	switch src[0] {
+	case 'b':
+		return '\b', 1
+	case 'f':
+		return '\f', 1
+	case 'n':
+		return '\n', 1
+	case 'r':
+		return '\r', 1
+	case 't':
+		return '\t', 1
+	}
+
+	// octal, hexadecimal and unicode escapes of up to the given number of
+	// digits
+	prefix, base, digits := 0, 8, 3
+	switch src[0] {
+	case 'x':
+		prefix, base, digits = 1, 16, 2
+	case 'u':
+		prefix, base, digits = 1, 16, 4
+	case 'U':
+		prefix, base, digits = 1, 16, 8
+	}
+
+	n := prefix
+	for n < len(src) && n < prefix+digits && isDigit(src[n], base) {
+		n++
+	}
+	if n == prefix {
+		return 0, 0
+	}
+	value, err := strconv.ParseUint(src[prefix:n], base, 32)
+	if err != nil {
+		return 0, 0
+	}
+	return rune(value), n
+}
+
+// isDigit reports whether c is a digit in the given base
+func isDigit(c byte, base int) bool {
+	if base == 8 {
+		return c >= '0' && c <= '7'
+	}
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// dollarTag returns the opening tag of a dollar quoted string, if any
+func dollarTag(src string) string {
+	for i := 1; i < len(src); i++ {
+		if src[i] == '$' {
+			return src[:i+1]
+		}
+		if src[i] != '_' && !unicode.IsLetter(rune(src[i])) && (i == 1 || !unicode.IsDigit(rune(src[i]))) {
+			return ""
+		}
+	}
+	return ""
+}
+
+// line returns the line number of the given offset
+func line(src string, offset int) int {
+	return strings.Count(src[:offset], "\n") + 1
+}
+
+// sqlParser applies the statements of the tokenized source to the schema
+type sqlParser struct {
+	src     string
+	tokens  []sqlToken
+	pos     int
+	dialect string
+	schema  *SQLSchema
+}
+
+// columnConstraintKeywords end types and default expressions of columns
+var columnConstraintKeywords = map[string]bool{
+	"not": true, "null": true, "primary": true, "unique": true, "default": true,
+	"references": true, "check": true, "constraint": true, "collate": true,
+	"generated": true, "autoincrement": true, "as": true, "on": true,
+}
+
+func (p *sqlParser) peek() sqlToken {
+	return p.tokens[p.pos]
+}
+
+func (p *sqlParser) peekAt(offset int) sqlToken {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *sqlParser) next() sqlToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != sqlEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// isKeyword reports whether the token is any of the given keywords
+func isKeyword(tok sqlToken, keywords ...string) bool {
+	if tok.kind != sqlIdent {
+		return false
+	}
+	for _, kw := range keywords {
+		if strings.EqualFold(tok.text, kw) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPunct(tok sqlToken, punct string) bool {
+	return tok.kind == sqlPunct && tok.text == punct
+}
+
+// accept consumes the given sequence of keywords if the next tokens match it
+func (p *sqlParser) accept(keywords ...string) bool {
+	for i, kw := range keywords {
+		if !isKeyword(p.peekAt(i), kw) {
+			return false
+		}
+	}
+	p.pos += len(keywords)
+	return true
+}
+
+func (p *sqlParser) acceptPunct(punct string) bool {
+	if isPunct(p.peek(), punct) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) expect(keywords ...string) error {
+	if !p.accept(keywords...) {
+		return p.unexpected(strings.ToUpper(strings.Join(keywords, " ")))
+	}
+	return nil
+}
+
+func (p *sqlParser) expectPunct(punct string) error {
+	if !p.acceptPunct(punct) {
+		return p.unexpected(punct)
+	}
+	return nil
+}
+
+// unexpected returns an error for the next token
+func (p *sqlParser) unexpected(expected string) error {
+	tok := p.peek()
+	found := "end of input"
+	if tok.kind != sqlEOF {
+		found = p.src[tok.start:tok.end]
+	}
+	return fmt.Errorf("line %d: expected %s, found %s", line(p.src, tok.start), expected, found)
+}
+
+// ident consumes an identifier. Unquoted identifiers are folded to lower
+// case in postgres.
+func (p *sqlParser) ident() (string, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case sqlIdent:
+		p.next()
+		if p.dialect == PostgresDialect {
+			return strings.ToLower(tok.text), nil
+		}
+		return tok.text, nil
+	case sqlQuotedIdent:
+		p.next()
+		return tok.text, nil
+	}
+	return "", p.unexpected("identifier")
+}
+
+// qualifiedName consumes a name optionally qualified with a schema
+func (p *sqlParser) qualifiedName() (string, string, error) {
+	name, err := p.ident()
+	if err != nil {
+		return "", "", err
+	}
+	if !p.acceptPunct(".") {
+		return "", name, nil
+	}
+
+	qualified, err := p.ident()
+	if err != nil {
+		return "", "", err
+	}
+	return name, qualified, nil
+}
+
+// identList consumes a parenthesized list of identifiers
+func (p *sqlParser) identList() ([]string, error) {
+	err := p.expectPunct("(")
+	if err != nil {
+		return nil, err
+	}
+
+	idents := make([]string, 0)
+	for {
+		ident, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		idents = append(idents, ident)
+
+		// collations and sort orders of sqlite key columns
+		if p.accept("collate") {
+			p.next()
+		}
+		p.accept("asc")
+		p.accept("desc")
+
+		if !p.acceptPunct(",") {
+			break
+		}
+	}
+
+	return idents, p.expectPunct(")")
+}
+
+// skip consumes tokens up to, but not including, the first token at the
+// current nesting level matching the predicate or ending the statement
+func (p *sqlParser) skip(stop func(sqlToken) bool) (int, int) {
+	start := p.peek().start
+	end := start
+
+	depth := 0
+	for {
+		tok := p.peek()
+		if tok.kind == sqlEOF || (depth == 0 && (isPunct(tok, ";") || stop(tok))) {
+			return start, end
+		}
+		if isPunct(tok, "(") || isPunct(tok, "[") {
+			depth++
+		}
+		if isPunct(tok, ")") || isPunct(tok, "]") {
+			if depth == 0 {
+				return start, end
+			}
+			depth--
+		}
+		p.next()
+		end = tok.end
+	}
+}
+
+// skipElement consumes tokens up to the next comma or closing parenthesis
+// at the current nesting level
+func (p *sqlParser) skipElement() (int, int) {
+	return p.skip(func(tok sqlToken) bool {
+		return isPunct(tok, ",")
+	})
+}
+
+// skipParens consumes a parenthesized expression
+func (p *sqlParser) skipParens() error {
+	err := p.expectPunct("(")
+	if err != nil {
+		return err
+	}
+	p.skip(func(sqlToken) bool { return false })
+	return p.expectPunct(")")
+}
+
+// text returns the source between the given offsets with whitespace
+// collapsed
+func (p *sqlParser) text(start, end int) string {
+	return strings.Join(strings.Fields(p.src[start:end]), " ")
+}
+
+func (p *sqlParser) parse() error {
+	for p.peek().kind != sqlEOF {
+		if p.acceptPunct(";") {
+			continue
+		}
+
+		var err error
+		switch {
+		case p.accept("create"):
+			err = p.create()
+		case p.accept("alter", "table"):
+			err = p.alterTable()
+		case p.accept("drop"):
+			err = p.drop()
+		}
+		if err != nil {
+			return err
+		}
+
+		// remaining clauses and other statements are ignored
+		p.skip(func(sqlToken) bool { return false })
+		if p.peek().kind != sqlEOF && !p.acceptPunct(";") {
+			// unbalanced closing parenthesis
+			p.next()
+		}
+	}
+
+	return nil
+}
+
+func (p *sqlParser) create() error {
+	p.accept("or", "replace")
+
+	switch {
+	case p.accept("unique", "index"):
+		return p.createIndex(true)
+	case p.accept("index"):
+		return p.createIndex(false)
+	case p.accept("type"):
+		return p.createType()
+	}
+
+	p.accept("global")
+	p.accept("local")
+	p.accept("temp")
+	p.accept("temporary")
+	p.accept("unlogged")
+	if p.accept("table") {
+		return p.createTable()
+	}
+
+	return nil
+}
+
+func (p *sqlParser) createTable() error {
+	p.accept("if", "not", "exists")
+
+	schema, name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+
+	table := &SQLTable{
+		Schema:      schema,
+		Name:        name,
+		Columns:     make([]*SQLColumn, 0),
+		PrimaryKey:  make([]string, 0),
+		ForeignKeys: make([]*SQLForeignKey, 0),
+		Uniques:     make([]*SQLUnique, 0),
+		Indexes:     make([]*SQLIndex, 0),
+	}
+
+	switch {
+	case p.accept("as"):
+		return fmt.Errorf("line %d: columns of table %s created from a query cannot be determined", line(p.src, p.peek().start), qualify(schema, name))
+	case p.accept("partition", "of"):
+		return p.createPartition(table)
+	}
+
+	err = p.expectPunct("(")
+	if err != nil {
+		return err
+	}
+	if p.acceptPunct(")") {
+		// tables without columns are valid in postgres
+		p.addTable(table)
+		return nil
+	}
+
+	for {
+		if p.accept("like") {
+			p.skipElement()
+		} else if p.isTableConstraint() {
+			err = p.tableConstraint(table)
+		} else {
+			err = p.columnDefinition(table)
+		}
+		if err != nil {
+			return err
+		}
+
+		if !p.acceptPunct(",") {
+			break
+		}
+	}
+
+	err = p.expectPunct(")")
+	if err != nil {
+		return err
+	}
+
+	p.addTable(table)
+	return nil
+}
+
+// createPartition consumes the parent of a partition, whose columns it
+// shares
+func (p *sqlParser) createPartition(table *SQLTable) error {
+	schema, name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+
+	parent := p.schema.Table(qualify(schema, name))
+	if parent == nil {
+		return fmt.Errorf("line %d: partition of unknown table %s", line(p.src, p.peek().start), qualify(schema, name))
+	}
+
+	for _, column := range parent.Columns {
+		copied := *column
+		table.Columns = append(table.Columns, &copied)
+	}
+	table.PrimaryKey = append(table.PrimaryKey, parent.PrimaryKey...)
+
+	p.addTable(table)
+	return nil
+}
+
+// addTable adds the created table to the schema, unless a table with the
+// same name exists
+func (p *sqlParser) addTable(table *SQLTable) {
+	if existing := p.schema.Table(qualify(table.Schema, table.Name)); existing != nil {
+		return
+	}
+	p.schema.Tables = append(p.schema.Tables, table)
+}
+
+func (p *sqlParser) isTableConstraint() bool {
+	tok := p.peek()
+	if isKeyword(tok, "primary", "foreign", "check", "exclude") {
+		return true
+	}
+	// unique is also a valid column name in sqlite
+	if isKeyword(tok, "unique") && isPunct(p.peekAt(1), "(") {
+		return true
+	}
+	return isKeyword(tok, "constraint")
+}
+
+func (p *sqlParser) tableConstraint(table *SQLTable) error {
+	name := ""
+	if p.accept("constraint") {
+		var err error
+		name, err = p.ident()
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case p.accept("primary", "key"):
+		columns, err := p.identList()
+		if err != nil {
+			return err
+		}
+		p.setPrimaryKey(table, name, columns)
+
+	case p.accept("unique"):
+		p.accept("nulls", "not", "distinct")
+		p.accept("nulls", "distinct")
+		columns, err := p.identList()
+		if err != nil {
+			return err
+		}
+		table.Uniques = append(table.Uniques, &SQLUnique{Name: name, Columns: columns})
+
+	case p.accept("foreign", "key"):
+		columns, err := p.identList()
+		if err != nil {
+			return err
+		}
+		err = p.expect("references")
+		if err != nil {
+			return err
+		}
+		fk, err := p.references(name, columns)
+		if err != nil {
+			return err
+		}
+		table.ForeignKeys = append(table.ForeignKeys, fk)
+
+	case p.accept("check"):
+		err := p.skipParens()
+		if err != nil {
+			return err
+		}
+
+	case p.accept("exclude"):
+		p.skipElement()
+
+	default:
+		return p.unexpected("table constraint")
+	}
+
+	// sqlite conflict clauses and postgres constraint attributes
+	p.skipElement()
+	return nil
+}
+
+func (p *sqlParser) setPrimaryKey(table *SQLTable, name string, columns []string) {
+	table.PrimaryKey = columns
+	table.primaryKeyName = name
+	for _, column := range table.Columns {
+		column.PrimaryKey = contains(columns, column.Name)
+		if column.PrimaryKey {
+			column.Nullable = false
+		}
+	}
+}
+
+func (p *sqlParser) columnDefinition(table *SQLTable) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+
+	column := &SQLColumn{
+		Name:     name,
+		Nullable: true,
+	}
+
+	err = p.columnType(column)
+	if err != nil {
+		return err
+	}
+
+	table.Columns = append(table.Columns, column)
+
+	for {
+		constraint := ""
+		if p.accept("constraint") {
+			constraint, err = p.ident()
+			if err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case p.accept("not", "null"):
+			column.Nullable = false
+
+		case p.accept("null"):
+			column.Nullable = true
+
+		case p.accept("primary", "key"):
+			p.accept("asc")
+			p.accept("desc")
+			p.setPrimaryKey(table, constraint, []string{column.Name})
+			if p.dialect == SQLiteDialect && column.BaseType == "integer" {
+				// integer primary keys are aliases of the rowid
+				column.AutoIncrement = true
+			}
+
+		case p.accept("unique"):
+			p.accept("nulls", "not", "distinct")
+			p.accept("nulls", "distinct")
+			column.Unique = true
+			table.Uniques = append(table.Uniques, &SQLUnique{Name: constraint, Columns: []string{column.Name}})
+
+		case p.accept("default"):
+			column.Default = p.defaultExpression()
+			column.HasDefault = true
+
+		case p.accept("references"):
+			fk, err := p.references(constraint, []string{column.Name})
+			if err != nil {
+				return err
+			}
+			column.References = fk
+			table.ForeignKeys = append(table.ForeignKeys, fk)
+
+		case p.accept("check"):
+			err := p.skipParens()
+			if err != nil {
+				return err
+			}
+
+		case p.accept("collate"):
+			_, _, err := p.qualifiedName()
+			if err != nil {
+				return err
+			}
+
+		case p.accept("autoincrement"):
+			column.AutoIncrement = true
+
+		case p.accept("generated"):
+			p.accept("always")
+			p.accept("by", "default")
+			err := p.expect("as")
+			if err != nil {
+				return err
+			}
+			if p.accept("identity") {
+				err = p.identity(column)
+			} else {
+				column.Generated = true
+				err = p.generatedExpression()
+			}
+			if err != nil {
+				return err
+			}
+
+		case p.accept("as"):
+			column.Generated = true
+			err := p.generatedExpression()
+			if err != nil {
+				return err
+			}
+
+		case p.accept("on", "conflict"):
+			p.next()
+
+		default:
+			if len(constraint) != 0 {
+				return p.unexpected("column constraint")
+			}
+			if tok := p.peek(); tok.kind == sqlEOF || isPunct(tok, ",") || isPunct(tok, ")") || isPunct(tok, ";") {
+				return nil
+			}
+			return p.unexpected(fmt.Sprintf("constraint of column %s", column.Name))
+		}
+	}
+}
+
+// identity consumes the sequence options of an identity column, which is
+// implicitly not null
+func (p *sqlParser) identity(column *SQLColumn) error {
+	column.AutoIncrement = true
+	column.Nullable = false
+	if isPunct(p.peek(), "(") {
+		return p.skipParens()
+	}
+	return nil
+}
+
+// defaultExpression consumes the default value expression of a column
+func (p *sqlParser) defaultExpression() string {
+	first := true
+	start, end := p.skip(func(tok sqlToken) bool {
+		if first {
+			first = false
+			return false
+		}
+		return isPunct(tok, ",") || (tok.kind == sqlIdent && columnConstraintKeywords[strings.ToLower(tok.text)])
+	})
+	return p.text(start, end)
+}
+
+// generatedExpression consumes the expression of a generated column
+func (p *sqlParser) generatedExpression() error {
+	err := p.skipParens()
+	if err != nil {
+		return err
+	}
+	p.accept("stored")
+	p.accept("virtual")
+	return nil
+}
+
+// columnType consumes the type of a column, if any. Types are optional in
+// sqlite.
+func (p *sqlParser) columnType(column *SQLColumn) error {
+	start := p.peek().start
+	end := start
+
+	base := &strings.Builder{}
+	quoted := false
+	for {
+		tok := p.peek()
+
+		if tok.kind == sqlQuotedIdent || (tok.kind == sqlIdent && !columnConstraintKeywords[strings.ToLower(tok.text)]) {
+			p.next()
+			end = tok.end
+
+			if base.Len() != 0 && !strings.HasSuffix(base.String(), ".") {
+				base.WriteString(" ")
+			}
+			if tok.kind == sqlQuotedIdent {
+				quoted = true
+				base.WriteString(tok.text)
+			} else {
+				base.WriteString(strings.ToLower(tok.text))
+			}
+
+			if p.acceptPunct(".") {
+				base.WriteString(".")
+			}
+			continue
+		}
+
+		if base.Len() != 0 && isPunct(tok, "(") {
+			err := p.skipParens()
+			if err != nil {
+				return err
+			}
+			end = p.tokens[p.pos-1].end
+			continue
+		}
+
+		if base.Len() != 0 && isPunct(tok, "[") {
+			p.next()
+			p.skip(func(sqlToken) bool { return false })
+			err := p.expectPunct("]")
+			if err != nil {
+				return err
+			}
+			end = p.tokens[p.pos-1].end
+			column.Array = true
+			continue
+		}
+
+		break
+	}
+
+	if base.Len() == 0 {
+		return nil
+	}
+
+	column.BaseType = base.String()
+	column.Type = p.text(start, end)
+	if !quoted {
+		column.Type = strings.ToLower(column.Type)
+	}
+
+	if p.dialect == PostgresDialect {
+		switch column.BaseType {
+		case "serial", "bigserial", "smallserial", "serial2", "serial4", "serial8":
+			column.AutoIncrement = true
+			column.Nullable = false
+		}
+	}
+
+	return nil
+}
+
+// references consumes the target and actions of a foreign key
+func (p *sqlParser) references(name string, columns []string) (*SQLForeignKey, error) {
+	schema, table, err := p.qualifiedName()
+	if err != nil {
+		return nil, err
+	}
+
+	fk := &SQLForeignKey{
+		Name:       name,
+		Columns:    columns,
+		RefSchema:  schema,
+		RefTable:   table,
+		RefColumns: make([]string, 0),
+	}
+
+	if isPunct(p.peek(), "(") {
+		fk.RefColumns, err = p.identList()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		switch {
+		case p.accept("on", "delete"):
+			fk.OnDelete = p.referentialAction()
+		case p.accept("on", "update"):
+			fk.OnUpdate = p.referentialAction()
+		case p.accept("match"):
+			p.next()
+		case p.accept("not", "deferrable"), p.accept("deferrable"):
+		case p.accept("initially"):
+			p.next()
+		default:
+			return fk, nil
+		}
+	}
+}
+
+func (p *sqlParser) referentialAction() string {
+	switch {
+	case p.accept("set", "null"):
+		return "SET NULL"
+	case p.accept("set", "default"):
+		return "SET DEFAULT"
+	case p.accept("no", "action"):
+		return "NO ACTION"
+	}
+	return strings.ToUpper(p.next().text)
+}
+
+func (p *sqlParser) createIndex(unique bool) error {
+	p.accept("concurrently")
+	p.accept("if", "not", "exists")
+
+	index := &SQLIndex{
+		Unique:  unique,
+		Columns: make([]string, 0),
+	}
+
+	if !isKeyword(p.peek(), "on") {
+		var err error
+		_, index.Name, err = p.qualifiedName()
+		if err != nil {
+			return err
+		}
+	}
+
+	err := p.expect("on")
+	if err != nil {
+		return err
+	}
+	p.accept("only")
+
+	index.Schema, index.Table, err = p.qualifiedName()
+	if err != nil {
+		return err
+	}
+
+	table := p.schema.Table(qualify(index.Schema, index.Table))
+	if table == nil {
+		return fmt.Errorf("line %d: index on unknown table %s", line(p.src, p.peek().start), qualify(index.Schema, index.Table))
+	}
+
+	if p.accept("using") {
+		p.next()
+	}
+
+	err = p.expectPunct("(")
+	if err != nil {
+		return err
+	}
+	for {
+		first := p.peek()
+		second := p.peekAt(1)
+		start, end := p.skipElement()
+
+		column := p.text(start, end)
+		if (first.kind == sqlIdent || first.kind == sqlQuotedIdent) &&
+			(isPunct(second, ",") || isPunct(second, ")") || second.kind == sqlIdent) {
+			// plain columns, optionally followed by sort orders,
+			// collations or operator classes
+			column = p.identText(first)
+		}
+		index.Columns = append(index.Columns, column)
+
+		if !p.acceptPunct(",") {
+			break
+		}
+	}
+	err = p.expectPunct(")")
+	if err != nil {
+		return err
+	}
+
+	for p.peek().kind != sqlEOF && !isPunct(p.peek(), ";") {
+		if p.accept("where") {
+			start, end := p.skip(func(sqlToken) bool { return false })
+			index.Where = p.text(start, end)
+			break
+		}
+		p.next()
+	}
+
+	p.schema.Indexes = append(p.schema.Indexes, index)
+	table.Indexes = append(table.Indexes, index)
+	return nil
+}
+
+// identText returns the identifier of the token as ident would
+func (p *sqlParser) identText(tok sqlToken) string {
+	if tok.kind == sqlIdent && p.dialect == PostgresDialect {
+		return strings.ToLower(tok.text)
+	}
+	return tok.text
+}
+
+func (p *sqlParser) createType() error {
+	schema, name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+
+	// composite, range and base types are not modelled
+	if !p.accept("as", "enum") {
+		return nil
+	}
+
+	enum := &SQLEnum{
+		Schema: schema,
+		Name:   name,
+		Values: make([]string, 0),
+	}
+
+	err = p.expectPunct("(")
+	if err != nil {
+		return err
+	}
+	for p.peek().kind == sqlString {
+		enum.Values = append(enum.Values, p.next().text)
+		if !p.acceptPunct(",") {
+			break
+		}
+	}
+	err = p.expectPunct(")")
+	if err != nil {
+		return err
+	}
+
+	p.schema.Enums = append(p.schema.Enums, enum)
+	return nil
+}
+
+func (p *sqlParser) alterTable() error {
+	p.accept("if", "exists")
+	p.accept("only")
+
+	schema, name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+
+	table := p.schema.Table(qualify(schema, name))
+	if table == nil {
+		return fmt.Errorf("line %d: alter of unknown table %s", line(p.src, p.peek().start), qualify(schema, name))
+	}
+
+	for {
+		err := p.alterTableAction(table)
+		if err != nil {
+			return err
+		}
+
+		// unsupported clauses of actions are ignored
+		p.skipElement()
+		if !p.acceptPunct(",") {
+			return nil
+		}
+	}
+}
+
+func (p *sqlParser) alterTableAction(table *SQLTable) error {
+	switch {
+	case p.accept("add"):
+		if p.isTableConstraint() {
+			return p.tableConstraint(table)
+		}
+		p.accept("column")
+		if p.accept("if", "not", "exists") {
+			name := p.identText(p.peek())
+			if table.Column(name) != nil {
+				p.skipElement()
+				return nil
+			}
+		}
+		return p.columnDefinition(table)
+
+	case p.accept("drop", "constraint"):
+		p.accept("if", "exists")
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		dropConstraint(table, name)
+
+	case p.accept("drop"):
+		p.accept("column")
+		p.accept("if", "exists")
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		dropColumn(table, name)
+
+	case p.accept("alter"):
+		p.accept("column")
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		column := table.Column(name)
+		if column == nil {
+			return fmt.Errorf("line %d: alter of unknown column %s.%s", line(p.src, p.peek().start), table.Name, name)
+		}
+		return p.alterColumn(column)
+
+	case p.accept("rename", "constraint"):
+		from, err := p.ident()
+		if err != nil {
+			return err
+		}
+		err = p.expect("to")
+		if err != nil {
+			return err
+		}
+		to, err := p.ident()
+		if err != nil {
+			return err
+		}
+		renameConstraint(table, from, to)
+
+	case p.accept("rename", "to"):
+		_, name, err := p.qualifiedName()
+		if err != nil {
+			return err
+		}
+		renameTable(p.schema, table, name)
+
+	case p.accept("rename"):
+		p.accept("column")
+		from, err := p.ident()
+		if err != nil {
+			return err
+		}
+		err = p.expect("to")
+		if err != nil {
+			return err
+		}
+		to, err := p.ident()
+		if err != nil {
+			return err
+		}
+		renameColumn(p.schema, table, from, to)
+	}
+
+	return nil
+}
+
+func (p *sqlParser) alterColumn(column *SQLColumn) error {
+	switch {
+	case p.accept("set", "not", "null"):
+		column.Nullable = false
+	case p.accept("drop", "not", "null"):
+		column.Nullable = true
+	case p.accept("set", "default"):
+		start, end := p.skipElement()
+		column.Default = p.text(start, end)
+		column.HasDefault = true
+	case p.accept("drop", "default"):
+		column.Default = ""
+		column.HasDefault = false
+	case p.accept("add", "generated"):
+		p.accept("always")
+		p.accept("by", "default")
+		err := p.expect("as", "identity")
+		if err != nil {
+			return err
+		}
+		return p.identity(column)
+	case p.accept("drop", "identity"):
+		p.accept("if", "exists")
+		column.AutoIncrement = false
+	case p.accept("set", "data", "type"), p.accept("type"):
+		column.Array = false
+		err := p.columnType(column)
+		if err != nil {
+			return err
+		}
+		p.accept("collate")
+	}
+	return nil
+}
+
+func (p *sqlParser) drop() error {
+	var drop func(schema, name string)
+	switch {
+	case p.accept("table"):
+		drop = func(schema, name string) {
+			table := p.schema.Table(qualify(schema, name))
+			if table == nil {
+				return
+			}
+			p.schema.Tables = removeTable(p.schema.Tables, table)
+			for _, index := range table.Indexes {
+				p.schema.Indexes = removeIndex(p.schema.Indexes, index)
+			}
+		}
+	case p.accept("index"):
+		p.accept("concurrently")
+		drop = func(schema, name string) {
+			for _, index := range p.schema.Indexes {
+				if index.Name == name {
+					p.schema.Indexes = removeIndex(p.schema.Indexes, index)
+					if table := p.schema.Table(qualify(index.Schema, index.Table)); table != nil {
+						table.Indexes = removeIndex(table.Indexes, index)
+					}
+					return
+				}
+			}
+		}
+	case p.accept("type"):
+		drop = func(schema, name string) {
+			enum := p.schema.Enum(qualify(schema, name))
+			for i, e := range p.schema.Enums {
+				if e == enum {
+					p.schema.Enums = append(p.schema.Enums[:i], p.schema.Enums[i+1:]...)
+					return
+				}
+			}
+		}
+	default:
+		return nil
+	}
+
+	p.accept("if", "exists")
+	for {
+		schema, name, err := p.qualifiedName()
+		if err != nil {
+			return err
+		}
+		drop(schema, name)
+
+		if !p.acceptPunct(",") {
+			return nil
+		}
+	}
+}
+
+// resolve links columns to their enum types and foreign keys without
+// referenced columns to the primary key of the referenced table
+func (p *sqlParser) resolve() {
+	for _, table := range p.schema.Tables {
+		for _, column := range table.Columns {
+			column.Enum = p.schema.Enum(column.BaseType)
+		}
+
+		for _, fk := range table.ForeignKeys {
+			if len(fk.RefColumns) != 0 {
+				continue
+			}
+			if ref := p.schema.Table(qualify(fk.RefSchema, fk.RefTable)); ref != nil {
+				fk.RefColumns = ref.PrimaryKey
+			}
+		}
+	}
+}
+
+func dropConstraint(table *SQLTable, name string) {
+	if table.primaryKeyName == name {
+		table.PrimaryKey = make([]string, 0)
+		for _, column := range table.Columns {
+			column.PrimaryKey = false
+		}
+	}
+
+	fks := make([]*SQLForeignKey, 0, len(table.ForeignKeys))
+	for _, fk := range table.ForeignKeys {
+		if fk.Name != name {
+			fks = append(fks, fk)
+			continue
+		}
+		for _, column := range table.Columns {
+			if column.References == fk {
+				column.References = nil
+			}
+		}
+	}
+	table.ForeignKeys = fks
+
+	uniques := make([]*SQLUnique, 0, len(table.Uniques))
+	for _, unique := range table.Uniques {
+		if unique.Name != name {
+			uniques = append(uniques, unique)
+			continue
+		}
+		if len(unique.Columns) == 1 {
+			if column := table.Column(unique.Columns[0]); column != nil {
+				column.Unique = false
+			}
+		}
+	}
+	table.Uniques = uniques
+}
+
+func dropColumn(table *SQLTable, name string) {
+	columns := make([]*SQLColumn, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		if column.Name != name {
+			columns = append(columns, column)
+		}
+	}
+	table.Columns = columns
+}
+
+func renameConstraint(table *SQLTable, from, to string) {
+	if table.primaryKeyName == from {
+		table.primaryKeyName = to
+	}
+	for _, fk := range table.ForeignKeys {
+		if fk.Name == from {
+			fk.Name = to
+		}
+	}
+	for _, unique := range table.Uniques {
+		if unique.Name == from {
+			unique.Name = to
+		}
+	}
+}
+
+func renameTable(schema *SQLSchema, table *SQLTable, name string) {
+	for _, t := range schema.Tables {
+		for _, fk := range t.ForeignKeys {
+			if fk.RefTable == table.Name && fk.RefSchema == table.Schema {
+				fk.RefTable = name
+			}
+		}
+	}
+	for _, index := range table.Indexes {
+		index.Table = name
+	}
+	table.Name = name
+}
+
+func renameColumn(schema *SQLSchema, table *SQLTable, from, to string) {
+	rename := func(names []string) {
+		for i, name := range names {
+			if name == from {
+				names[i] = to
+			}
+		}
+	}
+
+	if column := table.Column(from); column != nil {
+		column.Name = to
+	}
+	rename(table.PrimaryKey)
+	for _, fk := range table.ForeignKeys {
+		rename(fk.Columns)
+	}
+	for _, unique := range table.Uniques {
+		rename(unique.Columns)
+	}
+	for _, index := range table.Indexes {
+		rename(index.Columns)
+	}
+
+	for _, t := range schema.Tables {
+		for _, fk := range t.ForeignKeys {
+			if fk.RefTable == table.Name && fk.RefSchema == table.Schema {
+				rename(fk.RefColumns)
+			}
+		}
+	}
+}
+
+func removeTable(tables []*SQLTable, table *SQLTable) []*SQLTable {
+	for i, t := range tables {
+		if t == table {
+			return append(tables[:i], tables[i+1:]...)
+		}
+	}
+	return tables
+}
+
+func removeIndex(indexes []*SQLIndex, index *SQLIndex) []*SQLIndex {
+	for i, idx := range indexes {
+		if idx == index {
+			return append(indexes[:i], indexes[i+1:]...)
+		}
+	}
+	return indexes
+}
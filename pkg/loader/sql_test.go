@@ -0,0 +1,206 @@
+package loader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SQLLoader(t *testing.T) {
+	type input struct {
+		dialect string
+		sql     string
+	}
+
+	type want struct {
+		err        bool
+		errMessage string
+		check      func(t *testing.T, schema *SQLSchema)
+	}
+
+	tests := []struct {
+		name  string
+		input input
+		want  want
+	}{
+		{
+			name: "parses escape strings",
+			input: input{
+				sql: `CREATE TABLE notes (
+					body text DEFAULT E'it\'s\n' NOT NULL,
+					tag text CHECK (tag <> E'\\x41\u00e9')
+				);`,
+			},
+			want: want{
+				check: func(t *testing.T, schema *SQLSchema) {
+					body := schema.Table("notes").Column("body")
+					assert.Equal(t, `E'it\'s\n'`, body.Default)
+					assert.False(t, body.Nullable)
+					assert.NotNil(t, schema.Table("notes").Column("tag"))
+				},
+			},
+		},
+		{
+			name: "unescapes escape strings",
+			input: input{
+				sql: `CREATE TYPE mood AS ENUM (E'it\'s', E'tab\there', E'\x41\101\u00e9', 'plain''s');`,
+			},
+			want: want{
+				check: func(t *testing.T, schema *SQLSchema) {
+					assert.Equal(t, []string{"it's", "tab\there", "AAé", "plain's"}, schema.Enum("mood").Values)
+				},
+			},
+		},
+		{
+			name: "does not treat e as escape string prefix in sqlite",
+			input: input{
+				dialect: SQLiteDialect,
+				sql:     `CREATE TABLE t (a text DEFAULT 'x\');`,
+			},
+			want: want{
+				check: func(t *testing.T, schema *SQLSchema) {
+					assert.Equal(t, `'x\'`, schema.Table("t").Column("a").Default)
+				},
+			},
+		},
+		{
+			name: "parses tables without columns",
+			input: input{
+				sql: `CREATE TABLE empty ();`,
+			},
+			want: want{
+				check: func(t *testing.T, schema *SQLSchema) {
+					assert.NotNil(t, schema.Table("empty"))
+					assert.Empty(t, schema.Table("empty").Columns)
+				},
+			},
+		},
+		{
+			name: "copies columns of partitions",
+			input: input{
+				sql: `CREATE TABLE events (id bigint NOT NULL, at date, PRIMARY KEY (id, at)) PARTITION BY RANGE (at);
+				CREATE TABLE events_2024 PARTITION OF events FOR VALUES FROM ('2024-01-01') TO ('2025-01-01');`,
+			},
+			want: want{
+				check: func(t *testing.T, schema *SQLSchema) {
+					partition := schema.Table("events_2024")
+					assert.NotNil(t, partition)
+					assert.Len(t, partition.Columns, 2)
+					assert.Equal(t, []string{"id", "at"}, partition.PrimaryKey)
+				},
+			},
+		},
+		{
+			name: "rejects tables created from queries",
+			input: input{
+				sql: `CREATE TABLE copy AS SELECT * FROM users;`,
+			},
+			want: want{
+				err:        true,
+				errMessage: "columns of table copy created from a query cannot be determined",
+			},
+		},
+		{
+			name: "rejects tables without column list",
+			input: input{
+				sql: `CREATE TABLE t;`,
+			},
+			want: want{
+				err:        true,
+				errMessage: "line 1",
+			},
+		},
+		{
+			name: "rejects partitions of unknown tables",
+			input: input{
+				sql: `CREATE TABLE events_2024 PARTITION OF events FOR VALUES IN (1);`,
+			},
+			want: want{
+				err:        true,
+				errMessage: "partition of unknown table events",
+			},
+		},
+		{
+			name: "rejects indexes on unknown tables",
+			input: input{
+				sql: "CREATE TABLE users (id int);\n\nCREATE INDEX idx ON accounts (id);",
+			},
+			want: want{
+				err:        true,
+				errMessage: "line 3: index on unknown table accounts",
+			},
+		},
+		{
+			name: "rejects alters of unknown tables",
+			input: input{
+				sql: `ALTER TABLE accounts ADD COLUMN id int;`,
+			},
+			want: want{
+				err:        true,
+				errMessage: "alter of unknown table accounts",
+			},
+		},
+		{
+			name: "adds indexes to their table",
+			input: input{
+				sql: `CREATE TABLE app.users (id int, email text);
+				CREATE UNIQUE INDEX users_email ON app.users (lower(email)) WHERE id > 0;`,
+			},
+			want: want{
+				check: func(t *testing.T, schema *SQLSchema) {
+					table := schema.Table("app.users")
+					assert.Len(t, table.Indexes, 1)
+					assert.Equal(t, []string{"lower(email)"}, table.Indexes[0].Columns)
+					assert.Equal(t, "id > 0", table.Indexes[0].Where)
+				},
+			},
+		},
+		{
+			name: "makes identity columns not null",
+			input: input{
+				sql: `CREATE TABLE users (
+					id bigint GENERATED ALWAYS AS IDENTITY (START WITH 10),
+					seq int GENERATED BY DEFAULT AS IDENTITY,
+					legacy int
+				);
+				ALTER TABLE users ALTER COLUMN legacy ADD GENERATED ALWAYS AS IDENTITY;`,
+			},
+			want: want{
+				check: func(t *testing.T, schema *SQLSchema) {
+					for _, name := range []string{"id", "seq", "legacy"} {
+						column := schema.Table("users").Column(name)
+						assert.True(t, column.AutoIncrement, name)
+						assert.False(t, column.Nullable, name)
+					}
+				},
+			},
+		},
+		{
+			name: "drops identities",
+			input: input{
+				sql: `CREATE TABLE users (id int GENERATED ALWAYS AS IDENTITY);
+				ALTER TABLE users ALTER COLUMN id DROP IDENTITY IF EXISTS;`,
+			},
+			want: want{
+				check: func(t *testing.T, schema *SQLSchema) {
+					column := schema.Table("users").Column("id")
+					assert.False(t, column.AutoIncrement)
+					assert.False(t, column.Nullable)
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := (&SQLLoader{Dialect: tt.input.dialect}).LoadFromData([]byte(tt.input.sql))
+			if tt.want.err {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.want.errMessage)
+				return
+			}
+			assert.NoError(t, err)
+			tt.want.check(t, schema.(*SQLSchema))
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package loader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi2"
+	"github.com/getkin/kin-openapi/openapi2conv"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// Swagger2Loader loads Swagger 2.0 specifications and converts them to the
+// OpenAPI 3.0 model, so templates written for the openapi3 loader can be
+// reused
+type Swagger2Loader struct {
+	// RefRoot is the directory external references are restricted to, if set
+	RefRoot string
+}
+
+func (l *Swagger2Loader) LoadFromData(data []byte) (interface{}, error) {
+	doc2 := &openapi2.T{}
+	err := yaml.Unmarshal(data, doc2)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load from data")
+	}
+
+	doc, err := openapi2conv.ToV3(doc2)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to convert swagger v2 spec to openapi v3")
+	}
+
+	err = doc.Validate(context.Background())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to validate swagger v2 spec")
+	}
+	return doc, nil
+}
+
+// LoadFromFile loads the spec at the given location. External references
+// are resolved relative to the file containing them. Referenced schemas
+// are added to the definitions of the spec, other referenced objects are
+// inlined, as the conversion to OpenAPI 3.0 only supports local references.
+func (l *Swagger2Loader) LoadFromFile(location string) (interface{}, error) {
+	data, err := l.bundle(location)
+	if err != nil {
+		return nil, err
+	}
+	return l.LoadFromData(data)
+}
+
+// ResolveFileImports returns the paths of all files referenced by the spec
+// at the given location, directly or transitively. The spec is not loaded.
+func (l *Swagger2Loader) ResolveFileImports(location string) ([]string, error) {
+	reader, err := newRefReader(location, l.RefRoot, nil)
+	if err != nil {
+		return nil, err
+	}
+	return reader.imports(swagger2Fields, swagger2Values)
+}
+
+// bundle returns the encoded spec at the given location with all external
+// references resolved
+func (l *Swagger2Loader) bundle(location string) ([]byte, error) {
+	reader, err := newRefReader(location, l.RefRoot, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &swagger2Bundler{
+		reader: reader,
+		names:  map[string]string{},
+	}
+
+	node, err := reader.decode(reader.entry)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load from file")
+	}
+	doc, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("failed to load from file: %s is not a swagger v2 spec", location)
+	}
+
+	b.definitions, ok = doc["definitions"].(map[string]interface{})
+	if !ok {
+		b.definitions = map[string]interface{}{}
+	}
+
+	err = b.walker(reader.entry).walk(doc, openapiDocument, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load from file")
+	}
+	if len(b.definitions) != 0 {
+		doc["definitions"] = b.definitions
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to bundle swagger v2 spec")
+	}
+	return data, nil
+}
+
+// swagger2Fields are the fields of each kind of Swagger 2.0 object that may
+// hold references
+var swagger2Fields = map[openapiKind]map[string]openapiField{
+	openapiDocument: {
+		"paths":       {openapiPaths, openapiOne},
+		"definitions": {openapiSchema, openapiMap},
+		"parameters":  {openapiParameter, openapiMap},
+		"responses":   {openapiResponse, openapiMap},
+	},
+	openapiPathItem: {
+		"parameters": {openapiParameter, openapiList},
+		"get":        {openapiOperation, openapiOne},
+		"put":        {openapiOperation, openapiOne},
+		"post":       {openapiOperation, openapiOne},
+		"delete":     {openapiOperation, openapiOne},
+		"options":    {openapiOperation, openapiOne},
+		"head":       {openapiOperation, openapiOne},
+		"patch":      {openapiOperation, openapiOne},
+	},
+	openapiOperation: {
+		"parameters": {openapiParameter, openapiList},
+		"responses":  {openapiResponses, openapiOne},
+	},
+	openapiParameter: {
+		"schema": {openapiSchema, openapiOne},
+	},
+	openapiResponse: {
+		"schema": {openapiSchema, openapiOne},
+	},
+	openapiSchema: {
+		"properties":           {openapiSchema, openapiMap},
+		"additionalProperties": {openapiSchema, openapiOne},
+		"items":                {openapiSchema, openapiOne},
+		"allOf":                {openapiSchema, openapiList},
+	},
+}
+
+// swagger2Values are the kinds of Swagger 2.0 objects all of whose fields
+// hold objects of the same kind
+var swagger2Values = map[openapiKind]openapiKind{
+	openapiPaths:     openapiPathItem,
+	openapiResponses: openapiResponse,
+}
+
+// swagger2Bundler resolves the external references of a Swagger 2.0 spec
+type swagger2Bundler struct {
+	reader *refReader
+	// definitions are the schema definitions of the spec
+	definitions map[string]interface{}
+	// names are the names of the definitions added for referenced schemas
+	// by their file and JSON pointer
+	names map[string]string
+}
+
+// walker creates a walker resolving the references in the file at the given
+// absolute path
+func (b *swagger2Bundler) walker(path string) *openapiWalker {
+	return &openapiWalker{
+		fields: swagger2Fields,
+		values: swagger2Values,
+		ref: func(object map[string]interface{}, ref string, kind openapiKind) error {
+			return b.resolve(object, path, ref, kind)
+		},
+	}
+}
+
+// resolve rewrites the reference object found in the file at the given
+// absolute path, unless it is a local reference within the spec
+func (b *swagger2Bundler) resolve(object map[string]interface{}, path, ref string, kind openapiKind) error {
+	file, pointer, ok := resolveLocalRef(path, ref)
+	if !ok {
+		return fmt.Errorf("unsupported reference %s, only local files can be referenced", ref)
+	}
+	if file == b.reader.entry {
+		object["$ref"] = "#" + pointer
+		return nil
+	}
+
+	if kind == openapiSchema {
+		name, err := b.define(file, pointer)
+		if err != nil {
+			return err
+		}
+		object["$ref"] = "#/definitions/" + escapePointer(name)
+		return nil
+	}
+
+	value, err := b.lookup(file, pointer)
+	if err != nil {
+		return err
+	}
+	err = b.walker(file).walk(value, kind, pointer)
+	if err != nil {
+		return err
+	}
+
+	delete(object, "$ref")
+	for key, child := range value {
+		object[key] = child
+	}
+	return nil
+}
+
+// define adds the referenced schema to the definitions of the spec, unless
+// it already was, and returns the name of its definition
+func (b *swagger2Bundler) define(file, pointer string) (string, error) {
+	target := file + "#" + pointer
+	if name, ok := b.names[target]; ok {
+		return name, nil
+	}
+
+	schema, err := b.lookup(file, pointer)
+	if err != nil {
+		return "", err
+	}
+
+	// definitions are named after the referenced schema, or the file for
+	// references to whole files
+	base := unescapePointer(pointer[strings.LastIndex(pointer, "/")+1:])
+	if len(base) == 0 {
+		base = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	name := base
+	for i := 2; b.definitions[name] != nil; i++ {
+		name = base + strconv.Itoa(i)
+	}
+
+	// the name is taken before walking the schema, so recursive schemas
+	// refer to their own definition
+	b.names[target] = name
+	b.definitions[name] = schema
+	err = b.walker(file).walk(schema, openapiSchema, pointer)
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// lookup returns the object at the JSON pointer of the file at the given
+// absolute path
+func (b *swagger2Bundler) lookup(file, pointer string) (map[string]interface{}, error) {
+	node, err := b.reader.decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := lookupPointer(node, pointer)
+	object, isObject := value.(map[string]interface{})
+	if !ok || !isObject {
+		return nil, fmt.Errorf("reference %s#%s does not refer to an object", file, pointer)
+	}
+	return object, nil
+}
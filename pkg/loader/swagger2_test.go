@@ -0,0 +1,141 @@
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Swagger2Loader_Refs(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"swagger.yml": `
+swagger: "2.0"
+info:
+  title: Pets
+  version: 1.0.0
+paths:
+  /pets:
+    get:
+      parameters:
+        - $ref: ./parameters.yml#/Limit
+      responses:
+        "200":
+          $ref: ./responses.yml#/Pets
+definitions:
+  Tree:
+    type: object
+`,
+		"parameters.yml": `
+Limit:
+  name: limit
+  in: query
+  type: integer
+`,
+		"responses.yml": `
+Pets:
+  description: Pets
+  schema:
+    type: array
+    items:
+      $ref: ./tree.yml#/Tree
+`,
+		"tree.yml": `
+Tree:
+  type: object
+  properties:
+    children:
+      type: array
+      items:
+        $ref: "#/Tree"
+`,
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		assert.NoError(t, err)
+	}
+	location := filepath.Join(dir, "swagger.yml")
+
+	doc, err := (&Swagger2Loader{}).LoadFromFile(location)
+	assert.NoError(t, err)
+
+	op := doc.(*openapi3.T).Paths["/pets"].Get
+	assert.Equal(t, "limit", op.Parameters[0].Value.Name)
+	assert.Equal(t, "integer", op.Parameters[0].Value.Schema.Value.Type)
+
+	// referenced schemas are added to the definitions without replacing
+	// existing ones
+	items := op.Responses["200"].Value.Content["application/json"].Schema.Value.Items
+	assert.Equal(t, "#/components/schemas/Tree2", items.Ref)
+	tree := doc.(*openapi3.T).Components.Schemas["Tree2"].Value
+	assert.Equal(t, "#/components/schemas/Tree2", tree.Properties["children"].Value.Items.Ref)
+
+	refs, err := (&Swagger2Loader{}).ResolveFileImports(location)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		filepath.Join(dir, "parameters.yml"),
+		filepath.Join(dir, "responses.yml"),
+		filepath.Join(dir, "tree.yml"),
+	}, refs)
+
+	_, err = (&Swagger2Loader{RefRoot: filepath.Join(dir, "specs")}).LoadFromFile(location)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "is outside of")
+}
+
+func Test_Swagger2Loader_RefNames(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"swagger.yml": `
+swagger: "2.0"
+info:
+  title: Pets
+  version: 1.0.0
+paths:
+  /dogs:
+    get:
+      responses:
+        "200":
+          description: Dog
+          schema:
+            $ref: ./dogs.yml#/Pet
+  /cats:
+    get:
+      responses:
+        "200":
+          description: Cat
+          schema:
+            $ref: ./cats.yml#/Pet
+`,
+		"cats.yml": `
+Pet:
+  properties:
+    meow:
+      type: string
+`,
+		"dogs.yml": `
+Pet:
+  properties:
+    bark:
+      type: string
+`,
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		assert.NoError(t, err)
+	}
+
+	// definitions are named in the order the spec is walked, so the names
+	// do not change between loads
+	for i := 0; i < 20; i++ {
+		doc, err := (&Swagger2Loader{}).LoadFromFile(filepath.Join(dir, "swagger.yml"))
+		assert.NoError(t, err)
+
+		schemas := doc.(*openapi3.T).Components.Schemas
+		assert.Contains(t, schemas["Pet"].Value.Properties, "meow")
+		assert.Contains(t, schemas["Pet2"].Value.Properties, "bark")
+	}
+}
@@ -0,0 +1,27 @@
+package loader
+
+import (
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+type TomlLoader struct{}
+
+func (l *TomlLoader) LoadFromData(data []byte) (interface{}, error) {
+	var out map[string]interface{}
+	err := toml.Unmarshal(data, &out)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal toml")
+	}
+	return out, nil
+}
+
+func (l *TomlLoader) LoadFromFile(location string) (interface{}, error) {
+	byt, err := os.ReadFile(location)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read toml file")
+	}
+	return l.LoadFromData(byt)
+}